@@ -4,17 +4,28 @@
 package main
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"go_chirp_the_tap/internal/audio"
 	"go_chirp_the_tap/internal/constants"
 	"go_chirp_the_tap/internal/export"
 	"go_chirp_the_tap/internal/idx"
+	"go_chirp_the_tap/internal/options"
 	"go_chirp_the_tap/internal/tap"
+	"go_chirp_the_tap/version"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type OutputFormat string
@@ -24,76 +35,614 @@ const (
 	FormatPCM OutputFormat = "pcm"
 )
 
+// supported range of the output sample rate
+const (
+	minSampleRate = 8000
+	maxSampleRate = 192000
+)
+
+// supported range of the pulse amplitude around the 128 dc offset
+const (
+	minAmplitude = 1
+	maxAmplitude = 127
+)
+
 func main() {
 	// main entry point for the go_chirp_the_tap command-line tool.
 	// workflow summary:
 	// 1. parse flags (-format, -cpk, -csv, -clock) & get input .tap file path.
 	// 2. prepare output paths & select clock frequency (pal/ntsc).
-	// 3. read .tap file and optional associated .idx file.
-	// 4. call audio.processtapdata to get pcm samples & detailed segment index (indexData).
+	// 3. read .tap file (split into images if several are concatenated).
+	// 4. per image (convertTAP): read optional associated .idx file and call
+	//    audio.processtapdata to get pcm samples & detailed segment index (indexData).
 	// 5. generate final output (.cpk package or .wav/.pcm + optional .csv) based on flags.
 	// exits via log.fatal on critical errors.
 
-	// command-line arguments
+	// command-line arguments (defaults shared with the mobile api)
+	defaults := options.DefaultOptions()
 	format := flag.String("format", string(FormatWAV), "Output format (wav or pcm)")
+	bits := flag.Int("bits", 8, "Bits per sample for wav/pcm output and cpk blocks (8 = unsigned, 16 = signed, widened from the 8-bit samples)")
 	cpk := flag.Bool("cpk", false, "Create a cpk-package (.cpk archive with wav blocks and csv)")
 	csv := flag.Bool("csv", false, "Generate standalone CSV file (only if --cpk is not set)")
-	clockType := flag.String("clock", "pal", "Clock speed standard ('pal' or 'ntsc')")
-	targetSystem := flag.String("target", "c64", "Target system (e.g., c64, amstrad, spectrum)")
+	toTAP := flag.Bool("to-tap", false, "Decode a wav file argument back into a .tap file (<name>.decoded.tap) and exit")
+	decode := flag.Bool("decode", false, "Alias of -to-tap")
+	tapVersion := flag.Int("tap-version", 1, "Tap version written by -to-tap: 1 (exact pause lengths), 0 (pauses approximated) or 2 (half-waves)")
+	channels := flag.Int("channels", 1, "Audio channels of wav/pcm output and cpk blocks: 1 (mono) or 2 (the signal in both channels)")
+	invertRight := flag.Bool("invert-right", false, "With -channels 2, phase-invert the right channel (e.g. for head azimuth experiments)")
+	syncTrack := flag.String("sync-track", "", "Write stereo wav/pcm with a sync reference in the right channel: 'block' (marker at each block start) or 'edges' (marker at each pulse edge)")
+	cue := flag.Bool("cue", false, "Generate a .cue sheet with one track per block for the wav file (only if --cpk is not set)")
+	bwf := flag.Bool("bwf", false, "Write a broadcast wave (bwf) bext chunk with the source file, idx names and creation date into the wav output")
+	chapters := flag.Bool("chapters", false, "Generate an ffmpeg metadata file (.ffmetadata) with one chapter per block (only if --cpk is not set)")
+	clockType := flag.String("clock", defaults.ClockType, "Clock speed standard ('pal' or 'ntsc')")
+	targetSystem := flag.String("target", defaults.TargetSystem, "Target system (e.g., c64, amstrad, spectrum)")
+	leadPulse := flag.Int("lead-pulse", -1, "Expected lead tone pulse value (1-255, 0 = any repeated value; default depends on -target)")
+	leadTolerance := flag.Int("lead-tolerance", -1, "Allowed deviation from the lead pulse value (default depends on -target)")
+	leadGlitches := flag.Int("lead-glitches", 0, "Most non-matching bytes allowed within a lead tone's pilot window (0 = no limit, the 90% consistency decides)")
+	cyclesPerUnit := flag.Int("cycles-per-unit", constants.TapCyclesPerUnit, "CPU cycles per tap pulse byte unit (non-standard taps only)")
+	idxOffset := flag.String("idx-offset", "0", "Byte offset added to idx positions before merging (e.g. 20 or -20), or 'auto'")
+	flatten := flag.String("flatten", "", "Write a per-pulse analysis csv for a tap file byte range 'start:end' (e.g. 0x14:0x2000)")
+	splitSize := flag.Int("split-size", 0, "Split the cpk package into volumes of at most this many megabytes (0 = no split)")
+	tempDir := flag.String("temp-dir", "", "Directory for temp files while writing outputs (default: next to each output)")
+	keepTemp := flag.Bool("keep-temp", false, "Keep the temp files of failed outputs for debugging instead of removing them")
+	joinCPK := flag.String("join-cpk", "", "Reassemble a split cpk package from its .cpk.volumes.json index and exit")
+	checksums := flag.Bool("checksums", false, "Add a checksums.txt with the sha256 of every block wav to the cpk package")
+	dumpRawBlocks := flag.Bool("dump-raw-blocks", false, "Also write the raw tap bytes of every block to <name>_blocks/block_NNN_type.bin")
+	minBlock := flag.String("min-block-samples", "0", "Drop blocks shorter than this many samples, or milliseconds with 'ms' suffix (e.g. 5ms); 0 keeps all")
+	filterName := flag.String("filter-name", "", "Only export the programs whose idx tag matches this substring or glob pattern (case-insensitive, e.g. 'GAME*'); data blocks following a matched tag are kept with it")
+	explain := flag.Bool("explain", false, "Print for every block why it was classified as lead or data (first failing lead check)")
+	segmentStats := flag.Bool("segment-stats", false, "Print the block and pause length distribution and a suggested -min-block-samples value")
+	autoClean := flag.Bool("auto-clean", false, "Drop blocks shorter than the suggested minimum length (unless -min-block-samples is given)")
+	listIDX := flag.Bool("list-idx", false, "Print the entries parsed from the idx file (sibling of the tap file, or an .idx file argument) and exit")
+	validateIDX := flag.Bool("validate-idx", false, "Print how well the idx file matches the detected blocks")
+	reportJSON := flag.String("report-json", "", "Write a json summary of all converted tap images to this path (conversion errors are recorded instead of aborting)")
+	blockLeadIn := flag.Int("block-lead-in", 0, "Prepend this many samples at the pulse end level to every cpk block wav so its first pulse starts cleanly (0 = off)")
+	embedIDX := flag.Bool("embed-idx", false, "Store the original idx file as source.idx in the cpk package")
+	verifyCPK := flag.String("verify-cpk", "", "Check the internal consistency of a cpk package and exit")
+	infoCPK := flag.String("info-cpk", "", "Print the manifest, files and block summary of a cpk package and exit")
+	timeLimit := flag.Duration("time-limit", 0, "Abort the conversion of a tap image after this wall time (e.g. 30s, 2m) and move on; 0 = no limit")
+	groupPolicy := flag.String("group-policy", string(export.GroupLoose), "How index entries are grouped into blocks: 'loose' (lead/data with trailing pause), 'tight' (lead with following data up to the next lead) or 'per-entry'")
+	csvOrder := flag.String("csv-order", string(export.OrderPosition), "Row order of blocks.csv: 'position', 'type' or 'name' (idx tag); block file numbers keep the position order")
+	concat := flag.String("concat", "", "Join all tap file arguments into one tape, written as this output name (path without extension, e.g. mytape)")
+	concatGap := flag.Float64("concat-gap", 2, "Seconds of pause inserted between the joined taps of -concat")
+	appendCPK := flag.String("append-cpk", "", "Append the blocks to this existing cpk package instead of creating <name>.cpk (implies -cpk)")
+	trimLeader := flag.Bool("trim-leader", false, "Trim the blank tape (leading pause) before the first signal; its length is still recorded in the cpk manifest")
+	mergeBlocks := flag.Bool("merge-blocks", false, "Merge adjacent blocks of the same type (no pause in between) into one block")
+	serveAddr := flag.String("serve", "", "Run as http conversion service on this address (e.g. :8080) instead of converting a file")
+	maxUpload := flag.Int("max-upload", 64, "Maximum upload size in megabytes for -serve")
+	maxDownload := flag.Int("max-download", 64, "Maximum download size in megabytes for an http(s) url input")
+	fetchTimeout := flag.Int("fetch-timeout", 30, "Timeout in seconds for downloading an http(s) url input")
+	headSilence := flag.Float64("head-silence", 0, "Seconds of pause samples to prepend before the first block (leader for real tape decks)")
+	forceVersion := flag.Int("force-version", -1, "Override the tap header version byte (0, 1 or 2) for mis-tagged files; -1 uses the header")
+	padToSecond := flag.Bool("pad-to-second", false, "Pad the audio with pause samples to a whole number of seconds")
+	waveform := flag.String("waveform", defaults.Waveform, "Pulse waveform: 'square', 'sine' or 'triangle' (same duration and amplitude)")
+	amplitude := flag.Int("amplitude", int(defaults.Amplitude), fmt.Sprintf("Pulse amplitude around the centre level (%d-%d); lower values attenuate the output for line-level inputs", minAmplitude, maxAmplitude))
+	polarity := flag.String("polarity", string(audio.PolarityNormal), "Signal polarity: 'normal' (pulses start high) or 'inverted' (pulses start low)")
+	rounding := flag.String("rounding", string(audio.RoundFloor), "Rounding of pulse durations to whole samples: 'floor', 'round' or 'ceil'")
+	longPulseMax := flag.Int("long-pulse-max", 0, "v1 taps: treat overflow sequences within a block of up to this many cycles as one long pulse instead of a pause (0 = off)")
+	edgeRamp := flag.Int("edge-ramp", 0, "Soften pulse edges with a linear ramp of this many samples to reduce aliasing (0 = pure square wave)")
+	sampleRate := flag.Int("samplerate", defaults.SampleRate, fmt.Sprintf("Output sample rate in hz (%d-%d); pulses are generated and written at this rate", minSampleRate, maxSampleRate))
+	pauseTone := flag.Int("pause-tone", 0, "Generate pauses as a steady square carrier of this frequency in hz instead of one long pulse (0 = off)")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
+	trueSilence := flag.Bool("true-silence", false, "Use true silence for pauses instead of the safer pause pattern (may break loading on hardware)")
 	flag.Parse() // parse command-line arguments into defined flags
 
-	// access flag values and non-flag args below this point
+	// print the version and exit
+	if *showVersion {
+		fmt.Printf("go_chirp_the_tap %s\n", version.Info())
+		return
+	}
+
+	// reassemble a split package and exit - no tap file needed
+	if *joinCPK != "" {
+		outPath, err := export.JoinCPKVolumes(*joinCPK, export.TempPolicy{Dir: *tempDir, Keep: *keepTemp})
+		if err != nil {
+			log.Fatalf("Error joining cpk volumes: %v", err)
+		}
+		fmt.Printf("CPK package reassembled: %s\n", outPath)
+		return
+	}
+	// verify a package and exit - no tap file needed
+	if *verifyCPK != "" {
+		blockCount, err := export.VerifyCPK(*verifyCPK)
+		if err != nil {
+			log.Fatalf("CPK verification FAILED for %s: %v", *verifyCPK, err)
+		}
+		fmt.Printf("CPK package OK: %s (%d blocks verified)\n", *verifyCPK, blockCount)
+		return
+	}
+	if *infoCPK != "" {
+		info, err := export.InspectCPK(*infoCPK)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		printCPKInfo(*infoCPK, info)
+		return
+	}
+	if *blockLeadIn < 0 {
+		log.Fatalf("Error: invalid block lead-in %d (must be >= 0)", *blockLeadIn)
+	}
+	if *splitSize < 0 {
+		log.Fatalf("Error: invalid split size %d (must be >= 0)", *splitSize)
+	}
+
+	// apply overrides to the shared defaults
+	opts := defaults
+	opts.ClockType = *clockType
+	opts.TargetSystem = *targetSystem
+	if _, err := audio.ParseWaveform(*waveform); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	opts.Waveform = *waveform
+	if *amplitude < minAmplitude || *amplitude > maxAmplitude {
+		log.Fatalf("Error: invalid amplitude %d (must be %d-%d)", *amplitude, minAmplitude, maxAmplitude)
+	}
+	opts.Amplitude = byte(*amplitude)
+	opts.SampleRate = *sampleRate
+	if opts.SampleRate < minSampleRate || opts.SampleRate > maxSampleRate {
+		log.Fatalf("Error: invalid sample rate %d hz (must be %d-%d)", opts.SampleRate, minSampleRate, maxSampleRate)
+	}
+
+	// collect conversion settings and validate output format
+	// outputs are staged in temp files and moved into place once complete
+	fileSink := export.FileSink{Temp: export.TempPolicy{Dir: *tempDir, Keep: *keepTemp}}
+	cfg := convertConfig{
+		outputFormat: OutputFormat(*format),
+		cpk:          *cpk || *appendCPK != "",
+		appendCPK:    *appendCPK,
+		csv:          *csv,
+		cue:          *cue,
+		chapters:     *chapters,
+		bwf:          *bwf,
+		sampleRate:   opts.SampleRate,
+		targetSystem: opts.TargetSystem,
+		processOpts:  opts.ProcessOptions(),
+		packageOpts:  export.PackageOptions{SplitSize: int64(*splitSize) * 1024 * 1024, Checksums: *checksums, LeadIn: *blockLeadIn, Sink: fileSink},
+		flatten:      *flatten,
+		mergeBlocks:  *mergeBlocks,
+		dumpRaw:      *dumpRawBlocks,
+		validateIDX:  *validateIDX,
+		segmentStats: *segmentStats,
+		explain:      *explain,
+		autoClean:    *autoClean,
+		embedIDX:     *embedIDX,
+		padToSecond:  *padToSecond,
+		trimLeader:   *trimLeader,
+		filterName:   *filterName,
+		bits:         *bits,
+	}
+	if cfg.outputFormat != FormatWAV && cfg.outputFormat != FormatPCM {
+		log.Fatalf("Error: unsupported output format: %s. Use 'wav' or 'pcm'.", *format)
+	}
+	if cfg.bits != 8 && cfg.bits != 16 {
+		log.Fatalf("Error: unsupported bits per sample: %d. Use 8 or 16.", cfg.bits)
+	}
+	cfg.packageOpts.BitsPerSample = cfg.bits
+	if *syncTrack != "" {
+		mode, err := audio.ParseSyncMode(*syncTrack)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if cfg.cpk {
+			log.Printf("Warning: -sync-track applies to wav/pcm conversion only; cpk blocks stay mono.\n")
+		}
+		cfg.syncTrack = mode
+	}
+	if *channels != 1 && *channels != 2 {
+		log.Fatalf("Error: unsupported number of channels: %d. Use 1 or 2.", *channels)
+	}
+	if *channels == 2 && cfg.syncTrack != "" {
+		log.Fatalf("Error: -channels 2 and -sync-track both set the right channel, use only one of them")
+	}
+	if *invertRight && *channels != 2 {
+		log.Fatalf("Error: -invert-right requires -channels 2")
+	}
+	cfg.packageOpts.Channels, cfg.packageOpts.InvertRight = *channels, *invertRight
+
+	// get clock speed based on flag value
+	var err error
+	cfg.clock, err = selectClock(opts.ClockType)
+	if err != nil {
+		log.Fatalf("Error selecting clock: %v", err)
+	}
+
+	// lead detection parameters: target defaults, overridden by explicit flags
+	if *leadPulse >= 0 {
+		if *leadPulse > 255 {
+			log.Fatalf("Error: invalid lead pulse value %d (must be 0-255)", *leadPulse)
+		}
+		cfg.processOpts.LeadPulseValue = byte(*leadPulse)
+	}
+	if *leadTolerance >= 0 {
+		if *leadTolerance > 255 {
+			log.Fatalf("Error: invalid lead tolerance %d (must be 0-255)", *leadTolerance)
+		}
+		cfg.processOpts.LeadPulseTolerance = byte(*leadTolerance)
+	}
+	if *leadGlitches < 0 {
+		log.Fatalf("Error: invalid lead glitch count %d (must be >= 0)", *leadGlitches)
+	}
+	cfg.processOpts.LeadGlitches = *leadGlitches
+
+	if *cyclesPerUnit <= 0 {
+		log.Fatalf("Error: invalid cycles per unit %d (must be > 0)", *cyclesPerUnit)
+	}
+	cfg.processOpts.CyclesPerUnit = *cyclesPerUnit
+	cfg.processOpts.TrueSilence = *trueSilence
+	if *pauseTone < 0 || *pauseTone > cfg.sampleRate/2 {
+		log.Fatalf("Error: invalid pause tone %d hz (must be 0-%d)", *pauseTone, cfg.sampleRate/2)
+	}
+	cfg.processOpts.PauseToneHz = *pauseTone
+	if *edgeRamp < 0 {
+		log.Fatalf("Error: invalid edge ramp %d (must be >= 0)", *edgeRamp)
+	}
+	cfg.processOpts.EdgeRamp = *edgeRamp
+	if *longPulseMax < 0 {
+		log.Fatalf("Error: invalid long pulse maximum %d (must be >= 0)", *longPulseMax)
+	}
+	cfg.processOpts.LongPulseMaxCycles = uint32(*longPulseMax)
+	if *forceVersion < -1 || *forceVersion > constants.TapMaxVersionSupport {
+		log.Fatalf("Error: invalid forced tap version %d (use 0, 1 or 2, or -1 for the header version)", *forceVersion)
+	}
+	cfg.forceVersion = *forceVersion
+	if *headSilence < 0 {
+		log.Fatalf("Error: invalid head silence %g (must be >= 0)", *headSilence)
+	}
+	cfg.headSilenceSamples = int(math.Round(*headSilence * float64(cfg.sampleRate)))
+	if cfg.minBlockSamples, err = parseSampleCount(*minBlock, cfg.sampleRate); err != nil {
+		log.Fatalf("Error: invalid minimum block length '%s': %v", *minBlock, err)
+	}
+	if cfg.processOpts.Rounding, err = audio.ParseRoundingMode(*rounding); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if cfg.processOpts.Polarity, err = audio.ParsePolarity(*polarity); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	cfg.packageOpts.Polarity = cfg.processOpts.Polarity
+	cfg.packageOpts.Waveform = cfg.processOpts.Waveform
+	cfg.packageOpts.Amplitude = cfg.processOpts.Amplitude
+	if cfg.packageOpts.GroupPolicy, err = export.ParseGroupPolicy(*groupPolicy); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if cfg.packageOpts.CSVOrder, err = export.ParseBlockOrder(*csvOrder); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// idx position convention: explicit byte offset or auto-detection
+	if strings.ToLower(*idxOffset) == "auto" {
+		cfg.processOpts.IDXOffsetAuto = true
+	} else if cfg.processOpts.IDXOffset, err = strconv.Atoi(*idxOffset); err != nil {
+		log.Fatalf("Error: invalid idx offset '%s' (must be an integer or 'auto')", *idxOffset)
+	}
+
+	// run as conversion service instead of converting a file
+	if *serveAddr != "" {
+		if *maxUpload <= 0 {
+			log.Fatalf("Error: invalid max upload size %d (must be > 0)", *maxUpload)
+		}
+		log.Fatal(serve(*serveAddr, cfg, int64(*maxUpload)*1024*1024))
+	}
+
+	// access non-flag args below this point
 	args := flag.Args()
 	if len(args) < 1 {
 		log.Fatal("error: please provide a tap file path as an argument")
 	}
 	tapFilePath := args[0]
+
+	isURL := tap.IsURL(tapFilePath)
+
+	// decode a wav file back into a tap file and exit
+	if *toTAP || *decode {
+		if *tapVersion < 0 || *tapVersion > constants.TapMaxVersionSupport {
+			log.Fatalf("Error: invalid tap version %d (use 0, 1 or 2)", *tapVersion)
+		}
+		outPath, pulseCount, err := wavToTAP(tapFilePath, byte(*tapVersion), cfg)
+		if err != nil {
+			log.Fatalf("Error converting wav to tap: %v", err)
+		}
+		fmt.Printf("Decoded %d pulses into TAP file: %s\n", pulseCount, outPath)
+		return
+	}
+
+	// print the parsed idx entries and exit
+	if *listIDX {
+		if isURL {
+			log.Fatalf("Error: -list-idx requires a local file, not a url")
+		}
+		idxFilePath := tapFilePath
+		if !strings.EqualFold(filepath.Ext(tapFilePath), ".idx") {
+			idxFilePath = tapFilePath[:len(tapFilePath)-len(filepath.Ext(tapFilePath))] + ".idx"
+		}
+		if err := listIDXEntries(idxFilePath); err != nil {
+			log.Fatalf("Error listing IDX file: %v", err)
+		}
+		return
+	}
+
+	// join all tap file arguments into one output
+	if *concat != "" {
+		if *concatGap < 0 {
+			log.Fatalf("Error: invalid concat gap %g (must be >= 0)", *concatGap)
+		}
+		gapSamples := int(math.Round(*concatGap * float64(cfg.sampleRate)))
+		result := convertResult{Source: strings.Join(args, ", "), Image: 1, Status: "ok"}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if *timeLimit > 0 {
+			ctx, cancel = context.WithTimeout(ctx, *timeLimit)
+		}
+		err := concatTAPs(ctx, args, *concat, gapSamples, cfg, &result)
+		cancel()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if *reportJSON != "" {
+			writeReport(*reportJSON, []convertResult{result})
+		}
+		fmt.Println("Processing finished.")
+		return
+	}
+
 	fmt.Printf("Input TAP file: %s\n", tapFilePath)
 
-	// prep output path, name and extension
-	outputExt := filepath.Ext(tapFilePath)
-	baseFilePath := tapFilePath[:len(tapFilePath)-len(outputExt)]
-	outputFormat := OutputFormat(*format)
-	var outputAudioPath string
-	switch outputFormat {
-	case FormatWAV:
-		outputAudioPath = baseFilePath + ".wav"
-	case FormatPCM:
-		outputAudioPath = baseFilePath + ".pcm"
-	default:
-		log.Fatalf("Error: unsupported output format: %s. Use 'wav' or 'pcm'.", *format)
+	// read .tap file (or download it) - it may hold several concatenated tap images
+	var tapImages [][]byte
+	outputName := tapFilePath
+	if isURL {
+		if *maxDownload <= 0 || *fetchTimeout <= 0 {
+			log.Fatalf("Error: invalid download limits (-max-download %d, -fetch-timeout %d, both must be > 0)", *maxDownload, *fetchTimeout)
+		}
+		fmt.Printf("Downloading TAP file: %s\n", tapFilePath)
+		tapImages, err = tap.FetchTAPImages(tapFilePath, int64(*maxDownload)*1024*1024, time.Duration(*fetchTimeout)*time.Second)
+		// outputs go to the current directory, named after the url
+		outputName = tap.URLBaseName(tapFilePath)
+	} else {
+		fmt.Printf("Reading TAP file: %s\n", tapFilePath)
+		tapImages, err = tap.ReadTAPImages(tapFilePath)
+	}
+	if err != nil {
+		log.Fatalf("Error reading TAP file: %v", err)
 	}
-	outputCSVPath := baseFilePath + ".csv"
-	idxFilePath := baseFilePath + ".idx"
-	cpkPackagePath := baseFilePath + ".cpk"
 
-	// get clock speed based on flag value
-	selectedClock, err := selectClock(*clockType)
+	// prep output path without extension
+	outputExt := filepath.Ext(outputName)
+	baseFilePath := outputName[:len(outputName)-len(outputExt)]
+
+	// convert each tap image; concatenated images get numbered outputs (name_1, name_2, ...)
+	if len(tapImages) > 1 {
+		log.Printf("Warning: TAP file contains %d concatenated tap images, converting each separately.\n", len(tapImages))
+	}
+	var results []convertResult
+	for n, tapData := range tapImages {
+		imageBasePath := baseFilePath
+		if len(tapImages) > 1 {
+			imageBasePath = fmt.Sprintf("%s_%d", baseFilePath, n+1)
+			fmt.Printf("Converting tap image %d of %d: %s\n", n+1, len(tapImages), imageBasePath)
+		}
+		result := convertResult{Source: tapFilePath, Image: n + 1, Status: "ok"}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if *timeLimit > 0 {
+			ctx, cancel = context.WithTimeout(ctx, *timeLimit)
+		}
+		err := convertTAP(ctx, tapData, imageBasePath, cfg, &result)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			// a timed out image never stops the run, its outputs have been discarded
+			log.Printf("Error: conversion of %s exceeded the time limit of %s, skipped: %v", imageBasePath, *timeLimit, err)
+			result.Status, result.Error = "timeout", err.Error()
+		} else if err != nil {
+			if *reportJSON == "" {
+				log.Fatalf("Error: %v", err)
+			}
+			log.Printf("Error: %v", err)
+			result.Status, result.Error = "error", err.Error()
+		}
+		results = append(results, result)
+	}
+
+	// write the json summary of all conversions
+	if *reportJSON != "" {
+		writeReport(*reportJSON, results)
+	}
+
+	fmt.Println("Processing finished.")
+}
+
+// helper writing the json summary of all conversions to reportPath
+func writeReport(reportPath string, results []convertResult) {
+	reportData, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
-		log.Fatalf("Error selecting clock: %v", err)
+		log.Fatalf("Error marshaling report to json: %v", err)
+	}
+	if err := os.WriteFile(reportPath, reportData, 0644); err != nil {
+		log.Fatalf("Error writing report file '%s': %v", reportPath, err)
 	}
+	fmt.Printf("Report written: %s\n", reportPath)
+}
+
+// convertConfig holds the validated command-line settings used to convert a tap image.
+type convertConfig struct {
+	outputFormat       OutputFormat          // wav or pcm (direct conversion only)
+	cpk                bool                  // create a cpk package instead of a single audio file
+	appendCPK          string                // existing cpk package to append the blocks to ("" = create a new one)
+	csv                bool                  // write a standalone csv (direct conversion only)
+	cue                bool                  // write a cue sheet (direct wav conversion only)
+	chapters           bool                  // write an ffmpeg chapter file (direct conversion only)
+	bwf                bool                  // add a bwf bext chunk to the wav output (direct conversion only)
+	syncTrack          audio.SyncMode        // if set, add a sync reference as right channel (direct conversion only)
+	clock              float64               // selected clock frequency
+	sampleRate         int                   // audio sample rate in hz
+	targetSystem       string                // target system, recorded in the cpk manifest
+	processOpts        audio.ProcessOptions  // tuning parameters for tap processing
+	packageOpts        export.PackageOptions // settings for the cpk package
+	flatten            string                // byte range for the per-pulse analysis ("" = off)
+	mergeBlocks        bool                  // merge adjacent same-type blocks
+	padToSecond        bool                  // pad the audio to a whole number of seconds
+	dumpRaw            bool                  // write the raw tap bytes of every block
+	validateIDX        bool                  // print an idx match report
+	segmentStats       bool                  // print the block/pause length distribution
+	explain            bool                  // print why each block was classified as lead or data
+	autoClean          bool                  // apply the suggested minimum block length
+	embedIDX           bool                  // store the original idx file in the cpk package
+	forceVersion       int                   // tap version used instead of the header version byte (-1 = header)
+	headSilenceSamples int                   // pause samples prepended before the first block (0 = none)
+	trimLeader         bool                  // trim the blank tape before the first signal
+	minBlockSamples    int                   // drop blocks shorter than this many samples (0 keeps all)
+	filterName         string                // only export programs whose idx tag matches this pattern ("" keeps all)
+	bits               int                   // bits per sample of wav/pcm output (8 or 16)
+}
 
-	// declare vars for holding tap/idx data and processing results
-	var tapPayload []byte            // holds raw data blocks read from the .tap file
-	var tapVersion byte              // holds the version byte read from the .tap header
-	var idxEntries []idx.IDXEntry    // holds entries read from the optional .idx file (nil if no file)
-	var pcmSamples []byte            // holds the generated raw pcm audio sample data
-	var indexData []audio.IndexEntry // holds index metadata generated during audio processing
+// convertResult summarises the conversion of a single tap image for the -report-json report.
+type convertResult struct {
+	Source     string   `json:"source"`           // input .tap file path
+	Image      int      `json:"image"`            // number of the tap image within the file (1 unless concatenated)
+	Status     string   `json:"status"`           // "ok", "error" or "timeout"
+	Error      string   `json:"error,omitempty"`  // conversion error, if any
+	Blocks     int      `json:"blocks"`           // number of exported blocks
+	Names      []string `json:"names,omitempty"`  // distinct idx tags of the blocks
+	Duration   float64  `json:"duration"`         // audio duration in seconds
+	Output     string   `json:"output,omitempty"` // main output file (.cpk, .wav or .pcm)
+	OutputSize int64    `json:"output_size"`      // size of the main output file in bytes (all volumes if split)
+}
 
-	// read .tap file
-	fmt.Printf("Reading TAP file: %s\n", tapFilePath)
-	tapData, err := tap.ReadTAP(tapFilePath)
+// convertTAP converts a single tap image (tapData, including header) into the outputs
+// selected in cfg. output files are named after baseFilePath (path without extension);
+// an optional sibling baseFilePath.idx file is used for tagging. result is filled in
+// with a summary of the conversion as far as it got. once ctx is done, processing stops and
+// outputs being written are discarded.
+func convertTAP(ctx context.Context, tapData []byte, baseFilePath string, cfg convertConfig, result *convertResult) error {
+	idxFilePath := baseFilePath + ".idx"
+	tapVersion, idxEntries, pcmSamples, indexData, err := processTAP(ctx, tapData, idxFilePath, cfg)
 	if err != nil {
-		log.Fatalf("Error reading TAP file: %v", err)
+		return err
+	}
+
+	// optionally report how well the idx file matches the detected blocks
+	if cfg.validateIDX {
+		if len(idxEntries) == 0 {
+			log.Printf("Warning: -validate-idx requested, but no IDX entries were read.\n")
+		} else {
+			printIDXReport(indexData, idxEntries, cfg.processOpts)
+		}
+	}
+
+	// optionally explain the block classification
+	if cfg.explain {
+		for _, explanation := range audio.ExplainBlocks(tapData, indexData, cfg.processOpts) {
+			entry := explanation.Entry
+			if explanation.Reason == "" {
+				fmt.Printf("  %s block 0x%08x-0x%08x: lead tone accepted.\n", entry.Type, entry.StartPosition, entry.EndPosition)
+			} else {
+				fmt.Printf("  %s block 0x%08x-0x%08x: not a lead: %s.\n", entry.Type, entry.StartPosition, entry.EndPosition, explanation.Reason)
+			}
+		}
+	}
+
+	// optionally write the per-pulse analysis for the requested byte range
+	if cfg.flatten != "" {
+		startPos, endPos, err := parseByteRange(cfg.flatten)
+		if err != nil {
+			return fmt.Errorf("invalid -flatten range '%s': %w", cfg.flatten, err)
+		}
+		outputPulsesPath := baseFilePath + ".pulses.csv"
+		fmt.Printf("Writing pulse analysis for bytes 0x%x-0x%x: %s\n", startPos, endPos, outputPulsesPath)
+
+		pulses, err := audio.AnalysePulses(tapData, tapVersion, cfg.clock, float64(cfg.sampleRate), startPos, endPos, cfg.processOpts)
+		if err != nil {
+			return fmt.Errorf("error analysing pulses: %w", err)
+		}
+		if _, err = export.ExportPulseInfo(pulses, outputPulsesPath, float64(cfg.sampleRate)); err != nil {
+			return fmt.Errorf("error writing pulse analysis file '%s': %w", outputPulsesPath, err)
+		}
+		fmt.Printf("Pulse analysis written successfully (%d pulses).\n", len(pulses))
+	}
+
+	if len(idxEntries) == 0 {
+		idxFilePath = "" // nothing to embed
+	}
+	return exportTAP(ctx, tapData, pcmSamples, indexData, idxFilePath, baseFilePath, cfg, result)
+}
+
+// concatTAPs joins the tap images of all tapFilePaths into one tape, with gapSamples pause
+// samples between them, and writes the outputs selected in cfg named after baseFilePath.
+// every image is processed on its own (with its sibling idx file, if any); its idx tags are
+// prefixed with the image's file name and its first block is tagged with it if untagged, so
+// the blocks of each source stay identifiable.
+func concatTAPs(ctx context.Context, tapFilePaths []string, baseFilePath string, gapSamples int, cfg convertConfig, result *convertResult) error {
+	var tapData []byte // header of the first image followed by the payloads of all images
+	var pcmSamples []byte
+	var indexData []audio.IndexEntry
+	for _, tapFilePath := range tapFilePaths {
+		if tap.IsURL(tapFilePath) {
+			return fmt.Errorf("-concat requires local files, not a url: %s", tapFilePath)
+		}
+		fmt.Printf("Reading TAP file: %s\n", tapFilePath)
+		tapImages, err := tap.ReadTAPImages(tapFilePath)
+		if err != nil {
+			return fmt.Errorf("error reading TAP file: %w", err)
+		}
+
+		sourceBasePath := strings.TrimSuffix(tapFilePath, filepath.Ext(tapFilePath))
+		for n, image := range tapImages {
+			imageBasePath := sourceBasePath
+			if len(tapImages) > 1 {
+				imageBasePath = fmt.Sprintf("%s_%d", sourceBasePath, n+1) // as in single conversions
+			}
+			_, _, imagePCM, imageIndex, err := processTAP(ctx, image, imageBasePath+".idx", cfg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", imageBasePath, err)
+			}
+			labelSource(imageIndex, filepath.Base(imageBasePath))
+
+			if tapData == nil {
+				tapData = append([]byte(nil), image[:constants.TapHeaderSize]...)
+			} else if image[12] != tapData[12] {
+				log.Printf("Warning: %s is a v%d tap, the first tap is v%d; raw block dumps keep each block's own bytes.\n", imageBasePath, image[12], tapData[12])
+			}
+			pcmSamples, indexData = audio.AppendTape(pcmSamples, indexData, imagePCM, imageIndex, len(tapData)-constants.TapHeaderSize, cfg.sampleRate, gapSamples, cfg.processOpts)
+			tapData = append(tapData, image[constants.TapHeaderSize:]...)
+		}
+	}
+	if tapData == nil {
+		return fmt.Errorf("no tap images to join")
+	}
+	binary.LittleEndian.PutUint32(tapData[16:20], uint32(len(tapData)-constants.TapHeaderSize)) // joined payload size
+	fmt.Printf("Joined %d TAP files: %d PCM samples, %d index entries.\n", len(tapFilePaths), len(pcmSamples), len(indexData))
+
+	return exportTAP(ctx, tapData, pcmSamples, indexData, "", baseFilePath, cfg, result)
+}
+
+// helper prefixing the idx tags of a joined tap's index with label; the first block is tagged
+// with label alone if it has no tag
+func labelSource(indexData []audio.IndexEntry, label string) {
+	first := true
+	for i := range indexData {
+		entry := &indexData[i]
+		if entry.Type == "pause" {
+			continue
+		}
+		if entry.IDXTag != "" {
+			entry.IDXTag = label + ": " + entry.IDXTag
+		} else if first {
+			entry.IDXTag = label
+		}
+		first = false
 	}
+}
 
+// processTAP reads the header of a single tap image (tapData, including header) and the optional
+// idx file at idxFilePath, and generates the audio and index of the image.
+func processTAP(ctx context.Context, tapData []byte, idxFilePath string, cfg convertConfig) (tapVersion byte, idxEntries []idx.IDXEntry, pcmSamples []byte, indexData []audio.IndexEntry, err error) {
 	// ensure file is large enough to contain the expected header
 	if len(tapData) < constants.TapHeaderSize {
-		log.Fatalf("Invalid TAP file: shorter than header size (%d bytes)", constants.TapHeaderSize)
+		return 0, nil, nil, nil, fmt.Errorf("invalid TAP file: shorter than header size (%d bytes)", constants.TapHeaderSize)
+	}
+	tapVersion = tapData[12] // offset 12 holds the version byte in cbm tap header v0/v1/v2
+	if cfg.forceVersion >= 0 && byte(cfg.forceVersion) != tapVersion {
+		log.Printf("Warning: OVERRIDING TAP header version %d with forced version %d - pauses are decoded as v%d.\n", tapVersion, cfg.forceVersion, cfg.forceVersion)
+		tapVersion = byte(cfg.forceVersion)
 	}
-	tapVersion = tapData[12] // offset 12 holds the version byte in cbm tap header v0/v1
 
-	tapPayload = tapData[constants.TapHeaderSize:]
+	tapPayload := tapData[constants.TapHeaderSize:] // raw data blocks
 	fmt.Printf("TAP version: %d, Payload size: %d bytes\n", tapVersion, len(tapPayload))
 
 	// read .idx file
@@ -115,61 +664,456 @@ func main() {
 	// process .tap (and .idx if available)
 	fmt.Println("Processing TAP data into audio...")
 
-	pcmSamples, indexData, err = audio.ProcessTAPData(tapData, tapVersion, selectedClock, constants.SampleRate, idxEntries)
+	pcmSamples, indexData, err = audio.ProcessTAPDataContext(ctx, tapData, tapVersion, cfg.clock, float64(cfg.sampleRate), idxEntries, cfg.processOpts)
 	if err != nil {
-		log.Fatalf("Error processing TAP data: %v", err)
+		return 0, nil, nil, nil, fmt.Errorf("error processing TAP data: %w", err)
 	}
 	fmt.Printf("Generated %d PCM samples. Found %d raw index entries.\n", len(pcmSamples), len(indexData))
 
+	return tapVersion, idxEntries, pcmSamples, indexData, nil
+}
+
+// exportTAP cleans up the processed audio and index of a tap (tapData, including header) and
+// writes the outputs selected in cfg, named after baseFilePath. idxFilePath is the idx file
+// to embed into a cpk package ("" if none was read). once ctx is done, outputs being written
+// are discarded.
+func exportTAP(ctx context.Context, tapData []byte, pcmSamples []byte, indexData []audio.IndexEntry, idxFilePath string, baseFilePath string, cfg convertConfig, result *convertResult) error {
+	cfg.packageOpts.Sink = export.ContextSink(ctx, cfg.packageOpts.Sink)
+
+	// prep output paths
+	var outputAudioPath string
+	switch cfg.outputFormat {
+	case FormatWAV:
+		outputAudioPath = baseFilePath + ".wav"
+	case FormatPCM:
+		outputAudioPath = baseFilePath + ".pcm"
+	}
+	outputCSVPath := baseFilePath + ".csv"
+	outputCuePath := baseFilePath + ".cue"
+	outputChaptersPath := baseFilePath + ".ffmetadata"
+	cpkPackagePath := baseFilePath + ".cpk"
+	var err error
+
+	result.Duration = float64(len(pcmSamples)) / float64(cfg.sampleRate)
+	result.Names = idxNames(indexData)
+
+	// optionally merge split blocks of the same type
+	if cfg.mergeBlocks {
+		before := len(indexData)
+		indexData = audio.MergeAdjacentBlocks(indexData)
+		fmt.Printf("Merged adjacent blocks: %d index entries reduced to %d.\n", before, len(indexData))
+	}
+
+	// optionally report the segment lengths and derive the cleanup threshold from them
+	minBlockSamples := cfg.minBlockSamples
+	if cfg.segmentStats || cfg.autoClean {
+		stats := audio.AnalyseSegments(indexData)
+		if cfg.segmentStats {
+			printSegmentStats(stats, cfg.sampleRate)
+		}
+		if cfg.autoClean && minBlockSamples == 0 {
+			minBlockSamples = stats.SuggestedMinSamples
+			if minBlockSamples == 0 {
+				fmt.Println("Auto clean: no blocks look spurious, nothing to drop.")
+			}
+		}
+	}
+
+	// optionally drop tiny (spurious) blocks
+	if minBlockSamples > 0 {
+		var dropped int
+		indexData, dropped = audio.DropShortBlocks(indexData, minBlockSamples)
+		fmt.Printf("Dropped %d blocks shorter than %d samples.\n", dropped, minBlockSamples)
+	}
+
+	// optionally keep only the programs matching a name pattern
+	if cfg.filterName != "" {
+		var dropped int
+		if indexData, dropped, err = audio.FilterByName(indexData, cfg.filterName); err != nil {
+			return err
+		}
+		if len(indexData) == 0 {
+			log.Printf("Warning: no idx tag matches '%s', no blocks left to export.\n", cfg.filterName)
+		}
+		fmt.Printf("Name filter '%s': dropped %d blocks of other programs.\n", cfg.filterName, dropped)
+	}
+
+	// report the blank tape before the first signal and optionally trim it
+	if leaderSamples := audio.LeaderSilence(indexData); leaderSamples > 0 {
+		fmt.Printf("Leader silence before the first signal: %.3f s.\n", float64(leaderSamples)/float64(cfg.sampleRate))
+		if cfg.trimLeader {
+			var trimmed int
+			pcmSamples, indexData, trimmed = audio.TrimLeadingPause(pcmSamples, indexData, cfg.sampleRate)
+			cfg.packageOpts.TrimmedLeader = trimmed
+			fmt.Printf("Trimmed %d leader samples.\n", trimmed)
+		}
+	}
+
+	// optionally prepend a leader before the first block
+	if cfg.headSilenceSamples > 0 {
+		pcmSamples, indexData = audio.PrependPause(pcmSamples, indexData, cfg.sampleRate, cfg.headSilenceSamples, cfg.processOpts)
+		fmt.Printf("Prepended %d pause samples before the first block.\n", cfg.headSilenceSamples)
+	}
+
+	// optionally pad the run-off so the audio length is a whole number of seconds
+	if cfg.padToSecond {
+		before := len(pcmSamples)
+		pcmSamples, indexData = audio.PadToWholeSecond(pcmSamples, indexData, cfg.sampleRate, cfg.processOpts)
+		fmt.Printf("Padded audio with %d pause samples to a whole number of seconds.\n", len(pcmSamples)-before)
+	}
+
+	// optionally write the raw tap bytes of every block
+	if cfg.dumpRaw {
+		rawBlocksDir := baseFilePath + "_blocks"
+		fmt.Printf("Writing raw block bytes: %s\n", rawBlocksDir)
+		blockCount, err := export.DumpRawBlocks(tapData, indexData, rawBlocksDir, float64(cfg.sampleRate), cfg.packageOpts.GroupPolicy)
+		if err != nil {
+			return fmt.Errorf("error writing raw blocks: %w", err)
+		}
+		fmt.Printf("Raw bytes of %d blocks written successfully.\n", blockCount)
+	}
+
 	// generate output
-	if *cpk {
+	if cfg.cpk && cfg.appendCPK != "" {
+		fmt.Printf("Appending to cpk package: %s\n", cfg.appendCPK)
+		packageOpts := cfg.packageOpts
+		if cfg.embedIDX && idxFilePath != "" {
+			if packageOpts.SourceIDX, err = os.ReadFile(idxFilePath); err != nil {
+				return fmt.Errorf("error reading IDX file '%s' for embedding: %w", idxFilePath, err)
+			}
+		}
+		result.Blocks, err = export.AppendToCPK(cfg.appendCPK, pcmSamples, indexData, cfg.sampleRate, packageOpts)
+		if err != nil {
+			return fmt.Errorf("error appending to cpk package: %w", err)
+		}
+		result.Output = cfg.appendCPK
+		result.OutputSize = outputSize(cfg.appendCPK, false)
+		fmt.Printf("CPK package updated successfully.\n")
+	} else if cfg.cpk {
 		fmt.Printf("Creating cpk package: %s\n", cpkPackagePath)
 
-		err = export.SplitAndPackageBlocks(pcmSamples, indexData, baseFilePath, int(constants.SampleRate), selectedClock, *targetSystem)
+		// keep the original idx file in the package if requested
+		packageOpts := cfg.packageOpts
+		if cfg.embedIDX {
+			if idxFilePath == "" {
+				log.Printf("Warning: -embed-idx requested, but no IDX entries were read.\n")
+			} else if packageOpts.SourceIDX, err = os.ReadFile(idxFilePath); err != nil {
+				return fmt.Errorf("error reading IDX file '%s' for embedding: %w", idxFilePath, err)
+			}
+		}
+
+		result.Blocks, err = export.SplitAndPackageBlocks(pcmSamples, indexData, baseFilePath, cfg.sampleRate, cfg.clock, cfg.targetSystem, packageOpts)
 		if err != nil {
-			log.Fatalf("Error creating cpk package: %v", err)
+			return fmt.Errorf("error creating cpk package: %w", err)
 		}
+		result.Output = cpkPackagePath
+		result.OutputSize = outputSize(cpkPackagePath, cfg.packageOpts.SplitSize > 0)
 		fmt.Printf("CPK package created successfully.\n")
 	} else {
-		fmt.Printf("Writing audio file: %s (Format: %s)\n", outputAudioPath, outputFormat)
+		fmt.Printf("Writing audio file: %s (Format: %s, %d-bit)\n", outputAudioPath, cfg.outputFormat, cfg.bits)
 
-		switch outputFormat {
-		case FormatWAV:
-			err = audio.WriteWAVFile(outputAudioPath, pcmSamples, int(constants.SampleRate))
-		case FormatPCM:
-			err = os.WriteFile(outputAudioPath, pcmSamples, 0644)
+		audioData, channels, err := encodeAudio(pcmSamples, indexData, cfg)
+		if err != nil {
+			return err
 		}
+
+		// the optional bext chunk records where the audio came from
+		var bext *audio.BextInfo
+		if cfg.bwf {
+			if cfg.outputFormat != FormatWAV {
+				log.Printf("Warning: bext chunk requires wav output, skipping (format: %s).\n", cfg.outputFormat)
+			} else {
+				bext = bextInfo(result.Source, indexData)
+			}
+		}
+
+		err = export.WriteOutput(cfg.packageOpts.Sink, outputAudioPath, func(w io.Writer) error {
+			return writeAudio(w, cfg.outputFormat, audioData, channels, cfg, bext)
+		})
 		if err != nil {
-			log.Fatalf("Error writing audio file '%s': %v", outputAudioPath, err)
+			return fmt.Errorf("error writing audio file '%s': %w", outputAudioPath, err)
 		}
 		fmt.Printf("Audio file written successfully.\n")
+		result.Blocks = export.CountBlocks(indexData, float64(cfg.sampleRate), cfg.packageOpts.GroupPolicy)
+		result.Output = outputAudioPath
+		result.OutputSize = outputSize(outputAudioPath, false)
 
-		if *csv {
+		if cfg.csv {
 			fmt.Printf("Writing CSV file: %s\n", outputCSVPath)
 
-			_, err = export.ExportBlockInfo(indexData, outputCSVPath, constants.SampleRate)
+			_, err = export.ExportBlockInfo(indexData, outputCSVPath, float64(cfg.sampleRate), cfg.packageOpts.GroupPolicy, cfg.packageOpts.CSVOrder)
 			if err != nil {
-				log.Fatalf("Error writing CSV file '%s': %v", outputCSVPath, err)
+				return fmt.Errorf("error writing CSV file '%s': %w", outputCSVPath, err)
 			}
 			fmt.Printf("CSV file written successfully.\n")
 		} else {
 			fmt.Println("Standalone CSV file generation not requested (--csv flag not set).")
 		}
+
+		if cfg.cue {
+			if cfg.outputFormat != FormatWAV {
+				log.Printf("Warning: cue sheet requires wav output, skipping (format: %s).\n", cfg.outputFormat)
+			} else {
+				fmt.Printf("Writing cue file: %s\n", outputCuePath)
+
+				_, err = export.ExportCueSheet(indexData, filepath.Base(outputAudioPath), outputCuePath, float64(cfg.sampleRate), cfg.packageOpts.GroupPolicy)
+				if err != nil {
+					return fmt.Errorf("error writing cue file '%s': %w", outputCuePath, err)
+				}
+				fmt.Printf("Cue file written successfully.\n")
+			}
+		}
+
+		if cfg.chapters {
+			fmt.Printf("Writing chapter file: %s\n", outputChaptersPath)
+
+			_, err = export.ExportChapters(indexData, outputChaptersPath, float64(cfg.sampleRate), cfg.packageOpts.GroupPolicy)
+			if err != nil {
+				return fmt.Errorf("error writing chapter file '%s': %w", outputChaptersPath, err)
+			}
+			fmt.Printf("Chapter file written successfully.\n")
+		}
 	}
 
-	fmt.Println("Processing finished.")
+	return nil
+}
+
+// helper returning the distinct non-empty idx tags of indexData in tape order
+func idxNames(indexData []audio.IndexEntry) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, entry := range indexData {
+		name := strings.TrimSpace(entry.IDXTag)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// helper converting the generated 8-bit mono samples into the configured audio output: the
+// optional sync reference (or the -channels 2 copy) goes into the right channel, the data stays
+// left, and 16-bit output is recentred to signed samples around zero (per sample, so
+// interleaving is kept). it returns the interleaved audio data and its channel count.
+func encodeAudio(pcmSamples []byte, indexData []audio.IndexEntry, cfg convertConfig) ([]byte, int, error) {
+	audioData := pcmSamples
+	channels := 1
+	if cfg.syncTrack != "" {
+		track, err := audio.SyncTrack(pcmSamples, indexData, cfg.sampleRate, cfg.syncTrack)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error generating sync track: %w", err)
+		}
+		audioData = audio.InterleaveStereo(pcmSamples, track)
+		channels = 2
+		fmt.Printf("Added %s sync track as right channel.\n", cfg.syncTrack)
+	} else if cfg.packageOpts.Channels == 2 {
+		audioData = audio.DualChannel(pcmSamples, cfg.packageOpts.InvertRight)
+		channels = 2
+	}
+	if cfg.bits == 16 {
+		audioData = audio.ToSigned16(audioData)
+	}
+	return audioData, channels, nil
+}
+
+// helper writing audio data from encodeAudio to w, as a wav file (with a bext chunk if bext is
+// not nil) or as raw pcm
+func writeAudio(w io.Writer, format OutputFormat, audioData []byte, channels int, cfg convertConfig, bext *audio.BextInfo) error {
+	if format == FormatWAV {
+		return audio.WriteWAV(w, audioData, cfg.sampleRate, cfg.bits, channels, bext)
+	}
+	_, err := w.Write(audioData) // raw pcm
+	return err
+}
+
+// helper building the bwf bext chunk fields for a wav converted from source
+func bextInfo(source string, indexData []audio.IndexEntry) *audio.BextInfo {
+	description := "Converted from " + filepath.Base(source)
+	if names := idxNames(indexData); len(names) > 0 {
+		description += ": " + strings.Join(names, ", ")
+	}
+	now := time.Now()
+	return &audio.BextInfo{
+		Description:         description,
+		Originator:          "go_chirp_the_tap",
+		OriginatorReference: version.Version(),
+		OriginationDate:     now.Format("2006-01-02"),
+		OriginationTime:     now.Format("15:04:05"),
+	}
+}
+
+// helper returning the size of an output file, summing all volumes (path.001, ...) if split
+func outputSize(path string, split bool) int64 {
+	if !split {
+		if info, err := os.Stat(path); err == nil {
+			return info.Size()
+		}
+		return 0
+	}
+	var total int64
+	for n := 1; ; n++ {
+		info, err := os.Stat(fmt.Sprintf("%s.%03d", path, n))
+		if err != nil {
+			return total
+		}
+		total += info.Size()
+	}
 }
 
 // helper for pal/ntsc clock argument selector
 func selectClock(clockType string) (float64, error) {
-	switch strings.ToLower(clockType) {
-	case "ntsc":
-		fmt.Println("Using NTSC clock.")
-		return constants.ClockNTSC, nil
-	case "pal":
-		fmt.Println("Using PAL clock.")
-		return constants.ClockPAL, nil
-	default:
-		return 0, fmt.Errorf("invalid clock type '%s' (must be 'pal' or 'ntsc')", clockType)
+	clock, err := options.SelectClock(clockType)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Using %s clock.\n", strings.ToUpper(clockType))
+	return clock, nil
+}
+
+// helper for printing the entries of an idx file as parsed by idx.ReadIDX
+func listIDXEntries(idxFilePath string) error {
+	if _, err := os.Stat(idxFilePath); os.IsNotExist(err) {
+		return fmt.Errorf("idx file '%s' not found", idxFilePath)
+	}
+	idxEntries, err := idx.ReadIDX(idxFilePath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("IDX file %s: %d entries\n", idxFilePath, len(idxEntries))
+	for _, entry := range idxEntries {
+		fmt.Printf("%10d  0x%08x  %s\n", entry.Position, entry.Position, entry.Name)
+	}
+	return nil
+}
+
+// helper for printing an idx match report, using the same offset and matching as the merge
+func printIDXReport(indexData []audio.IndexEntry, idxEntries []idx.IDXEntry, opts audio.ProcessOptions) {
+	offset := opts.IDXOffset
+	if opts.IDXOffsetAuto {
+		offset = audio.BestIDXOffset(indexData, idxEntries)
+	}
+	report := audio.ValidateIDX(indexData, idx.ShiftPositions(idxEntries, offset), constants.MaxOffset)
+
+	fmt.Printf("IDX report (offset %d, tolerance %d bytes): %d of %d entries tag a block.\n", offset, constants.MaxOffset, report.Matched, len(idxEntries))
+	for _, entry := range report.UnmatchedIDX {
+		fmt.Printf("  unmatched idx entry: 0x%08x %s\n", entry.Position, entry.Name)
+	}
+	for _, entry := range report.ShadowedIDX {
+		fmt.Printf("  shadowed idx entry (block tagged by a later entry): 0x%08x %s\n", entry.Position, entry.Name)
+	}
+	for _, block := range report.UntaggedBlocks {
+		fmt.Printf("  untagged %s block: 0x%08x-0x%08x\n", block.Type, block.StartPosition, block.EndPosition)
+	}
+}
+
+// helper for decoding a wav file into a tap image of the given version, written next to it as
+// <name>.decoded.tap (so a round trip never overwrites the original tap file)
+func wavToTAP(wavPath string, version byte, cfg convertConfig) (string, int, error) {
+	file, err := os.Open(wavPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("error opening wav file '%s': %w", wavPath, err)
+	}
+	defer file.Close()
+
+	samples, sampleRate, err := audio.ReadWAVSamples(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading wav file '%s': %w", wavPath, err)
+	}
+	pulses, err := audio.DecodePulses(samples, sampleRate, cfg.clock)
+	if err != nil {
+		return "", 0, err
+	}
+	tapData, err := tap.EncodeTAP(pulses, version)
+	if err != nil {
+		return "", 0, err
+	}
+
+	outPath := strings.TrimSuffix(wavPath, filepath.Ext(wavPath)) + ".decoded.tap"
+	err = export.WriteOutput(cfg.packageOpts.Sink, outPath, func(w io.Writer) error {
+		_, err := w.Write(tapData)
+		return err
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("error writing tap file '%s': %w", outPath, err)
+	}
+	return outPath, len(pulses), nil
+}
+
+// helper for printing the block and pause length distribution of a segment analysis
+func printSegmentStats(stats audio.SegmentStats, sampleRate int) {
+	ms := func(samples int) float64 { return float64(samples) * 1000 / float64(sampleRate) }
+	for _, row := range []struct {
+		name  string
+		stats audio.LengthStats
+	}{{"blocks", stats.Blocks}, {"pauses", stats.Pauses}} {
+		fmt.Printf("Segment lengths, %s: %d, min %d (%.1f ms), median %d (%.1f ms), max %d (%.1f ms) samples.\n", row.name, row.stats.Count,
+			row.stats.Min, ms(row.stats.Min), row.stats.Median, ms(row.stats.Median), row.stats.Max, ms(row.stats.Max))
+	}
+	if stats.SuggestedMinSamples > 0 {
+		fmt.Printf("Suggested -min-block-samples %d (%.1f ms).\n", stats.SuggestedMinSamples, ms(stats.SuggestedMinSamples))
+	} else {
+		fmt.Println("Suggested -min-block-samples 0 (no blocks look spurious).")
+	}
+}
+
+// helper printing the summary of a cpk package
+func printCPKInfo(cpkPath string, info export.CPKInfo) {
+	fmt.Printf("CPK package: %s\n", cpkPath)
+	manifestData, _ := json.MarshalIndent(info.Manifest, "  ", "  ") // plain struct, cannot fail
+	fmt.Printf("Manifest:\n  %s\n", manifestData)
+
+	fmt.Printf("Files (%d):\n", len(info.Members))
+	for _, member := range info.Members {
+		fmt.Printf("  %-28s %10d bytes\n", member.Name, member.Size)
+	}
+
+	types := make([]string, 0, len(info.BlockTypes))
+	for blockType := range info.BlockTypes {
+		types = append(types, blockType)
+	}
+	sort.Strings(types)
+	var counts []string
+	for _, blockType := range types {
+		counts = append(counts, fmt.Sprintf("%d %s", info.BlockTypes[blockType], blockType))
+	}
+	fmt.Printf("Blocks: %d (%s), total duration %.3f s.\n", info.Blocks, strings.Join(counts, ", "), info.Duration)
+}
+
+// helper for parsing a sample count, either plain samples or milliseconds with 'ms' suffix
+func parseSampleCount(value string, sampleRate int) (int, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if ms, ok := strings.CutSuffix(value, "ms"); ok {
+		milliseconds, err := strconv.ParseFloat(strings.TrimSpace(ms), 64)
+		if err != nil || milliseconds < 0 {
+			return 0, fmt.Errorf("expected a non-negative number of milliseconds")
+		}
+		return int(math.Round(milliseconds * float64(sampleRate) / 1000)), nil
+	}
+	samples, err := strconv.Atoi(value)
+	if err != nil || samples < 0 {
+		return 0, fmt.Errorf("expected a non-negative number of samples or milliseconds (e.g. 5ms)")
+	}
+	return samples, nil
+}
+
+// helper for parsing a 'start:end' tap file byte range (decimal or 0x-prefixed hex)
+func parseByteRange(byteRange string) (int, int, error) {
+	parts := strings.SplitN(byteRange, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected 'start:end'")
+	}
+	startPos, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 0, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start: %w", err)
+	}
+	endPos, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 0, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end: %w", err)
+	}
+	if startPos < 0 || endPos < startPos {
+		return 0, 0, fmt.Errorf("start must be >= 0 and not after end")
 	}
+	return int(startPos), int(endPos), nil
 }
@@ -0,0 +1,151 @@
+// cmd/serve.go
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/export"
+	"go_chirp_the_tap/internal/options"
+	"go_chirp_the_tap/internal/tap"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// serve runs the http conversion service on addr until it fails.
+// POST /convert accepts a .tap file (raw request body or multipart form field "file") and
+// streams back the converted output. query parameters override the command-line settings:
+//   - format: "wav" (default), "pcm" or "cpk"
+//   - clock: "pal" or "ntsc"
+//   - target: target system (e.g., "c64")
+//
+// uploads larger than maxUpload bytes are rejected; a client disconnect aborts processing.
+func serve(addr string, cfg convertConfig, maxUpload int64) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+		handleConvert(w, r, cfg, maxUpload)
+	})
+
+	fmt.Printf("Serving conversions on %s (POST /convert, max upload %d bytes)\n", addr, maxUpload)
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleConvert handles a single POST /convert request.
+func handleConvert(w http.ResponseWriter, r *http.Request, cfg convertConfig, maxUpload int64) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// read the upload, enforcing the size limit
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	tapData, sourceName, err := readUpload(r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("upload exceeds %d bytes", maxUpload), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("error reading upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := tap.ValidateTAP(tapData, "upload"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// apply query parameter overrides
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = string(FormatWAV)
+	}
+	if format != string(FormatWAV) && format != string(FormatPCM) && format != "cpk" {
+		http.Error(w, fmt.Sprintf("unsupported format '%s' (use wav, pcm or cpk)", format), http.StatusBadRequest)
+		return
+	}
+	if clockType := query.Get("clock"); clockType != "" {
+		if cfg.clock, err = options.SelectClock(clockType); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if target := query.Get("target"); target != "" {
+		cfg.targetSystem = target
+		cfg.processOpts.LeadPulseValue, cfg.processOpts.LeadPulseTolerance = audio.LeadPulseForTarget(target)
+	}
+
+	tapVersion := tapData[12]
+	if cfg.forceVersion >= 0 {
+		tapVersion = byte(cfg.forceVersion)
+	}
+
+	// process in memory; the request context is cancelled if the client disconnects
+	pcmSamples, indexData, err := audio.ProcessTAPDataContext(r.Context(), tapData, tapVersion, cfg.clock, float64(cfg.sampleRate), nil, cfg.processOpts)
+	if err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("Conversion of '%s' aborted: %v\n", sourceName, err)
+			return
+		}
+		http.Error(w, fmt.Sprintf("error processing tap data: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// wav and pcm output honour the configured bit depth and channels like the cli
+	var audioData []byte
+	var channels int
+	if format != "cpk" {
+		if audioData, channels, err = encodeAudio(pcmSamples, indexData, cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	// stream back the result
+	baseName := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+	switch format {
+	case "cpk":
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", baseName+".cpk"))
+		_, err = export.WritePackage(w, pcmSamples, indexData, sourceName, cfg.sampleRate, cfg.clock, cfg.targetSystem, cfg.packageOpts)
+	case string(FormatWAV):
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", baseName+".wav"))
+		err = writeAudio(w, FormatWAV, audioData, channels, cfg, nil)
+	case string(FormatPCM):
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", baseName+".pcm"))
+		err = writeAudio(w, FormatPCM, audioData, channels, cfg, nil)
+	}
+	if err != nil {
+		// headers are already sent at this point, so the error can only be logged
+		log.Printf("Error sending %s output for '%s': %v\n", format, sourceName, err)
+	}
+}
+
+// readUpload returns the uploaded .tap data and its file name, either from the multipart
+// form field "file" or from the raw request body.
+func readUpload(r *http.Request) ([]byte, string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		return data, filepath.Base(header.Filename), err
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, r.Body); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "upload.tap", nil
+}
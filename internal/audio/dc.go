@@ -0,0 +1,36 @@
+// internal/audio/dc.go
+
+package audio
+
+import "fmt"
+
+// EstimateDC returns the dc level (mean sample value) of unsigned 8-bit pcm samples for each
+// consecutive window of window samples; the last window may be shorter. for a clean capture
+// the level is close to 128; a biased capture drifts away from it, so the per-window level is
+// the threshold to use for edge (zero crossing) detection instead of a fixed 128.
+func EstimateDC(samples []byte, window int) ([]float64, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid dc window size: %d", window)
+	}
+
+	levels := make([]float64, 0, (len(samples)+window-1)/window)
+	for start := 0; start < len(samples); start += window {
+		end := min(start+window, len(samples))
+		sum := 0
+		for _, sample := range samples[start:end] {
+			sum += int(sample)
+		}
+		levels = append(levels, float64(sum)/float64(end-start))
+	}
+	return levels, nil
+}
+
+// IsHigh reports whether sample i lies above the dc level of its window, as returned by
+// EstimateDC with the same window size. samples beyond the estimated range use 128.
+func IsHigh(samples []byte, levels []float64, window int, i int) bool {
+	threshold := 128.0
+	if window > 0 && i/window < len(levels) {
+		threshold = levels[i/window]
+	}
+	return float64(samples[i]) > threshold
+}
@@ -0,0 +1,73 @@
+// internal/audio/decode.go
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ReadWAVSamples reads an uncompressed pcm wav from r and returns its first channel as
+// unsigned 8-bit samples centred on 128 (16-bit samples are reduced to their high byte).
+func ReadWAVSamples(r io.Reader) (samples []byte, sampleRate int, err error) {
+	sampleRate, bitsPerSample, channels, dataSize, err := ParseWAVHeader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return nil, 0, fmt.Errorf("unsupported wav format: %d bits", bitsPerSample)
+	}
+	data := make([]byte, dataSize)
+	n, err := io.ReadFull(r, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, fmt.Errorf("error reading wav data: %w", err)
+	}
+	data = data[:n] // tolerate a truncated data chunk
+
+	frameSize := channels * bitsPerSample / 8
+	samples = make([]byte, 0, len(data)/frameSize)
+	for frame := 0; frame+frameSize <= len(data); frame += frameSize {
+		if bitsPerSample == 8 {
+			samples = append(samples, data[frame])
+		} else {
+			samples = append(samples, byte(int(int16(binary.LittleEndian.Uint16(data[frame:])))>>8+128))
+		}
+	}
+	return samples, sampleRate, nil
+}
+
+// DecodePulses measures the pulses of a tape signal as the distance between consecutive rising
+// edges (crossings above the dc level of a one second window, see EstimateDC) and returns
+// their durations in cpu cycles of clock. a signal starting high counts as an edge at sample 0,
+// otherwise the signal before the first edge is skipped; the last pulse runs to the end. pulses generated by ProcessTAPData start high
+// (unless inverted, which shifts the edges by half a pulse), so decoding its output reproduces the original pulse (and pause) durations to within a sample.
+func DecodePulses(samples []byte, sampleRate int, clock float64) ([]uint32, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	levels, err := EstimateDC(samples, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	cyclesPerSample := clock / float64(sampleRate)
+	var pulses []uint32
+	lastEdge := -1
+	if len(samples) > 0 && IsHigh(samples, levels, sampleRate, 0) {
+		lastEdge = 0
+	}
+	for i := 1; i < len(samples); i++ {
+		if IsHigh(samples, levels, sampleRate, i) && !IsHigh(samples, levels, sampleRate, i-1) {
+			if lastEdge >= 0 {
+				pulses = append(pulses, uint32(math.Round(float64(i-lastEdge)*cyclesPerSample)))
+			}
+			lastEdge = i
+		}
+	}
+	if lastEdge >= 0 && lastEdge < len(samples) {
+		pulses = append(pulses, uint32(math.Round(float64(len(samples)-lastEdge)*cyclesPerSample)))
+	}
+	return pulses, nil
+}
@@ -0,0 +1,29 @@
+// internal/audio/explain.go
+
+package audio
+
+// BlockExplanation records why a block was classified as it was.
+type BlockExplanation struct {
+	Entry  IndexEntry
+	Reason string // first failing lead check for data blocks, "" for leads
+}
+
+// ExplainBlocks re-runs the lead tone check of ProcessTAPData at the start of every lead and
+// data block in indexData (as returned by ProcessTAPData for tapData, before any merging or
+// dropping) and reports, for each data block, the first check that rejected it as a lead.
+// pauses are not listed.
+func ExplainBlocks(tapData []byte, indexData []IndexEntry, opts ProcessOptions) []BlockExplanation {
+	var explanations []BlockExplanation
+	for _, entry := range indexData {
+		if entry.Type != "lead" && entry.Type != "data" {
+			continue
+		}
+		if entry.StartPosition < 0 || entry.StartPosition >= len(tapData) {
+			explanations = append(explanations, BlockExplanation{Entry: entry, Reason: "start position outside tap data"})
+			continue
+		}
+		_, reason := _explainLeadTone(tapData, entry.StartPosition, opts.LeadPulseValue, opts.LeadPulseTolerance, opts.LeadGlitches)
+		explanations = append(explanations, BlockExplanation{Entry: entry, Reason: reason})
+	}
+	return explanations
+}
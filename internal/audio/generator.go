@@ -2,11 +2,16 @@
 package audio
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"go_chirp_the_tap/internal/constants"
 	"go_chirp_the_tap/internal/idx"
+	"io"
 	"math"
+	"path"
 	"sort"
+	"strings"
 )
 
 // struct holding metadata for each data segment, ie. a block detected during .tap processing.
@@ -20,18 +25,209 @@ type IndexEntry struct {
 	IDXTag        string  // holds matching tag from .idx file (set during merge); empty if no file or no match
 }
 
+// StartMillis returns the start time of the entry in milliseconds.
+func (e IndexEntry) StartMillis() float64 {
+	return e.StartTime * 1000
+}
+
+// DurationSamples returns the length of the entry in samples (start and end are inclusive),
+// or 0 for an invalid range.
+func (e IndexEntry) DurationSamples() int {
+	return max(0, e.EndSample-e.StartSample+1)
+}
+
+// DurationMillis returns the length of the entry in milliseconds at sampleRate
+// (the entry does not store its sample rate), or 0 for an invalid sample rate.
+func (e IndexEntry) DurationMillis(sampleRate float64) float64 {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return float64(e.DurationSamples()) * 1000 / sampleRate
+}
+
+// EndTime returns the time in seconds right after the last sample of the entry at sampleRate,
+// i.e. the start time of an immediately following entry.
+func (e IndexEntry) EndTime(sampleRate float64) float64 {
+	if sampleRate <= 0 {
+		return e.StartTime
+	}
+	return e.StartTime + float64(e.DurationSamples())/sampleRate
+}
+
+// ByteLength returns the number of tap file bytes the entry covers (start and end are inclusive).
+func (e IndexEntry) ByteLength() int {
+	return max(0, e.EndPosition-e.StartPosition+1)
+}
+
+// ProcessOptions holds optional tuning parameters for ProcessTAPData.
+// the zero value keeps the generic detection behaviour.
+type ProcessOptions struct {
+	LeadPulseValue     byte         // expected pulse value of a lead tone; 0 accepts a run of any identical value
+	LeadPulseTolerance byte         // allowed deviation from LeadPulseValue for a byte to count as lead
+	LeadGlitches       int          // if > 0, the most non-matching bytes (glitches of real captures) allowed within a lead's pilot window; 0 leaves it to RequiredConsistency
+	CyclesPerUnit      int          // cpu cycles per tap pulse byte unit; 0 uses the standard tap scaling (8)
+	IDXOffset          int          // byte offset added to every idx position before merging
+	IDXOffsetAuto      bool         // if true, pick the idx offset (0 or +/- header size) that tags the most blocks; overrides IDXOffset
+	TrueSilence        bool         // if true, pauses are true silence (128) instead of the safer 255/1 pause pattern, see _generatePause
+	PauseToneHz        int          // if > 0, pauses are a steady square carrier of this frequency instead of one long pulse, see _generatePause
+	Rounding           RoundingMode // how cycle durations are rounded to whole samples; "" rounds down (floor)
+	EdgeRamp           int          // samples of linear ramp at each pulse edge to soften transitions; 0 keeps a pure square wave
+	LongPulseMaxCycles uint32       // v1 only: a 0x00 overflow within or directly before a block up to this many cycles is one long pulse, not a pause; 0 disables
+	Polarity           Polarity     // signal polarity of pulses and pauses; "" is normal (high first)
+	Waveform           Waveform     // shape of the generated pulses; "" is square
+	Amplitude          byte         // pulse and pause amplitude (1-127) around the 128 dc offset; 0 uses the full 127
+}
+
+// amplitude returns the pulse amplitude to generate with (full scale unless set).
+func (o ProcessOptions) amplitude() byte {
+	if o.Amplitude == 0 || o.Amplitude > 127 {
+		return 127
+	}
+	return o.Amplitude
+}
+
+// isLongPulse reports whether a v1 overflow sequence of the given cycles inside (or opening) a block
+// is treated as a single long pulse (e.g. a fastloader sync pulse) instead of a pause.
+func (o ProcessOptions) isLongPulse(version byte, cycles uint32) bool {
+	return version >= 1 && o.LongPulseMaxCycles > 0 && cycles > 0 && cycles <= o.LongPulseMaxCycles
+}
+
+// RoundingMode selects how cyclesToSamples rounds a duration to a whole number of samples.
+type RoundingMode string
+
+const (
+	RoundFloor   RoundingMode = "floor" // round down, never exceeds the original duration (default)
+	RoundNearest RoundingMode = "round" // round to the nearest sample
+	RoundCeil    RoundingMode = "ceil"  // round up, never undershoots a pulse
+)
+
+// ParseRoundingMode validates a rounding mode name ("floor", "round" or "ceil").
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch mode := RoundingMode(strings.ToLower(name)); mode {
+	case RoundFloor, RoundNearest, RoundCeil:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported rounding mode '%s' (use floor, round or ceil)", name)
+}
+
+// Polarity selects whether pulses (and pauses) start with their high or their low half.
+type Polarity string
+
+const (
+	PolarityNormal   Polarity = "normal"   // high first, then low (default)
+	PolarityInverted Polarity = "inverted" // low first, then high
+)
+
+// ParsePolarity validates a polarity name ("normal" or "inverted").
+func ParsePolarity(name string) (Polarity, error) {
+	switch polarity := Polarity(strings.ToLower(name)); polarity {
+	case PolarityNormal, PolarityInverted:
+		return polarity, nil
+	}
+	return "", fmt.Errorf("unsupported polarity '%s' (use normal or inverted)", name)
+}
+
+// Waveform selects the shape of a generated pulse. every shape spends the first half of the
+// pulse above and the second half below the dc level, with the same duration and amplitude.
+type Waveform string
+
+const (
+	WaveSquare   Waveform = "square"   // hard high/low halves (default)
+	WaveSine     Waveform = "sine"     // one full sine cycle, fewer harmonics for sensitive amplifiers
+	WaveTriangle Waveform = "triangle" // one full triangle cycle
+)
+
+// ParseWaveform validates a waveform name ("square", "sine" or "triangle").
+func ParseWaveform(name string) (Waveform, error) {
+	switch waveform := Waveform(strings.ToLower(name)); waveform {
+	case WaveSquare, WaveSine, WaveTriangle:
+		return waveform, nil
+	}
+	return "", fmt.Errorf("unsupported waveform '%s' (use square, sine or triangle)", name)
+}
+
+// applyPolarity mirrors samples around the 128 dc level in place for inverted polarity,
+// so the high and low levels swap and phase continuity between blocks and pauses is kept.
+func (o ProcessOptions) applyPolarity(samples []byte) []byte {
+	if o.Polarity == PolarityInverted {
+		_invertSamples(samples)
+	}
+	return samples
+}
+
+// LeadInLevel returns the level to lead into a block with: the level generated pulses end on
+// (their low half at amplitude amp, or their high half with inverted polarity), so the first
+// pulse of the block starts with a clean edge away from it. an amp of 0 uses the full 127.
+func LeadInLevel(amp byte, polarity Polarity) byte {
+	o := ProcessOptions{Amplitude: amp, Polarity: polarity}
+	return o.applyPolarity([]byte{128 - o.amplitude()})[0]
+}
+
+// _invertSamples mirrors unsigned 8-bit samples around the 128 dc level in place (0 clips to 255).
+func _invertSamples(samples []byte) {
+	for i, sample := range samples {
+		samples[i] = byte(min(256-int(sample), 255))
+	}
+}
+
+// cyclesPerUnit returns the pulse byte to cycles scaling, falling back to the tap standard.
+func (o ProcessOptions) cyclesPerUnit() uint32 {
+	if o.CyclesPerUnit > 0 {
+		return uint32(o.CyclesPerUnit)
+	}
+	return constants.TapCyclesPerUnit
+}
+
+// LeadPulseForTarget returns the expected lead pulse value and tolerance for a target system.
+// unknown targets return zero values, which fall back to the generic identical-run detection.
+func LeadPulseForTarget(targetSystem string) (value, tolerance byte) {
+	switch strings.ToLower(targetSystem) {
+	case "c64":
+		return constants.LeadPulseC64, constants.LeadPulseToleranceC64
+	default:
+		return 0, 0
+	}
+}
+
 // ProcessTAPData converts raw .tap data into PCM samples
 // and builds a slice of IndexEntry structs (one per detected block)
 // and merges optional IDX data into it
 // and returns the resulting slice.
-func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idxEntries []idx.IDXEntry) ([]byte, []IndexEntry, error) {
-	if len(tapData) < constants.TapHeaderSize {
-		return nil, nil, fmt.Errorf("tap data too short: %d bytes, expected at least %d", len(tapData), constants.TapHeaderSize)
+func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idxEntries []idx.IDXEntry, opts ProcessOptions) ([]byte, []IndexEntry, error) {
+	return ProcessTAPDataContext(context.Background(), tapData, version, clock, sampleRate, idxEntries, opts)
+}
+
+// ProcessTAPDataContext is like ProcessTAPData but stops early with the context's error
+// once ctx is cancelled (checked between blocks), e.g. when a client disconnects.
+func ProcessTAPDataContext(ctx context.Context, tapData []byte, version byte, clock, sampleRate float64, idxEntries []idx.IDXEntry, opts ProcessOptions) ([]byte, []IndexEntry, error) {
+	// pcmSamples buffer is pre-allocated to the sample count of a cheap first pass over the
+	// pulse and pause durations, avoiding repeated reallocations and copies on large tapes.
+	pcmSamples := bytes.NewBuffer(make([]byte, 0, _estimateSamples(tapData, version, clock, sampleRate, opts)))
+	indexData, err := StreamTAPDataContext(ctx, tapData, version, clock, sampleRate, idxEntries, opts, pcmSamples)
+	if err != nil {
+		return nil, nil, err
 	}
+	return pcmSamples.Bytes(), indexData, nil
+}
 
-	// pcmSamples slice starts empty; capacity grows dynamically via append. no pre-allocation
-	// was used due to difficulty finding a reliable heuristic for tap files, esp. due to pauses.
-	pcmSamples := make([]byte, 0)
+// StreamTAPData is like ProcessTAPData but writes the pcm samples to w as each block is
+// generated instead of collecting them, so peak memory is bounded by one block rather than
+// the whole tape. the returned index is the same as ProcessTAPData's.
+// on error, w may already hold the samples of the blocks before the failing one.
+func StreamTAPData(tapData []byte, version byte, clock, sampleRate float64, idxEntries []idx.IDXEntry, opts ProcessOptions, w io.Writer) ([]IndexEntry, error) {
+	return StreamTAPDataContext(context.Background(), tapData, version, clock, sampleRate, idxEntries, opts, w)
+}
+
+// StreamTAPDataContext is like StreamTAPData but stops early with the context's error
+// once ctx is cancelled (checked between blocks).
+func StreamTAPDataContext(ctx context.Context, tapData []byte, version byte, clock, sampleRate float64, idxEntries []idx.IDXEntry, opts ProcessOptions, w io.Writer) ([]IndexEntry, error) {
+	if len(tapData) < constants.TapHeaderSize {
+		return nil, fmt.Errorf("tap data too short: %d bytes, expected at least %d", len(tapData), constants.TapHeaderSize)
+	}
+	// a valid header with an empty payload would otherwise silently produce zero samples
+	if len(tapData) == constants.TapHeaderSize {
+		return nil, fmt.Errorf("tap contains no data blocks (header only, empty payload)")
+	}
 
 	// indexData slice capacity pre-allocated to a fixed size (512) based on observed max entries.
 	// this value is generous compared to what test observations showed and avoids reallocations
@@ -45,6 +241,11 @@ func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idx
 	// main loop: process tapdata byte stream block by block.
 	// 'i' advances based on the number of bytes consumed by each block.
 	for i < len(tapData) {
+		// stop if the caller gave up on the result
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("processing aborted at file offset %d: %w", currentPosition, err)
+		}
+
 		// mark start position/sample for the current block
 		sectionStartSample := currentSample
 		sectionStartPosition := currentPosition
@@ -57,16 +258,17 @@ func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idx
 
 		b := tapData[i]
 
-		// dispatch block processing based on current byte (0 = pause, non-zero = data/lead)
-		if b == 0 {
+		// dispatch block processing based on current byte (0 = pause, non-zero = data/lead).
+		// a short v1 overflow directly followed by pulses opens the following data block instead
+		if b == 0 && !_startsWithLongPulse(tapData, i, version, opts) {
 			var cycles uint32 // limited to this block scope
-			blockPCM, blockBytesRead, cycles, err = _processPauseBlock(tapData, i, version, clock, sampleRate)
+			blockPCM, blockBytesRead, cycles, err = _processPauseBlock(tapData, i, version, clock, sampleRate, opts)
 			_ = cycles // assign cycles value to blank - avoiding unused variable error.
 			blockType = "pause"
 		} else {
 			var isLead bool
 			var totalCycles uint32 // limited to this block scope
-			blockPCM, isLead, blockBytesRead, totalCycles, err = _processDataLeadBlock(tapData, i, clock, sampleRate)
+			blockPCM, isLead, blockBytesRead, totalCycles, err = _processDataLeadBlock(tapData, i, version, clock, sampleRate, opts)
 			_ = totalCycles // assign cycles value to blank - avoiding unused variable error.
 			if isLead {
 				blockType = "lead"
@@ -77,7 +279,7 @@ func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idx
 
 		// handle processing errors reported by helper functions
 		if err != nil {
-			return nil, nil, fmt.Errorf("error processing tap block starting at file offset %d: %w", sectionStartPosition, err)
+			return nil, fmt.Errorf("error processing tap block starting at file offset %d: %w", sectionStartPosition, err)
 		}
 		// safety check to prevent infinite loop if a block processor returns zero bytes read - probably redundant; better safe than sorry.
 		if blockBytesRead <= 0 {
@@ -85,9 +287,11 @@ func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idx
 			break
 		}
 
-		// append generated audio samples
+		// write generated audio samples
 		if len(blockPCM) > 0 {
-			pcmSamples = append(pcmSamples, blockPCM...)
+			if _, err := w.Write(blockPCM); err != nil {
+				return nil, fmt.Errorf("error writing pcm samples of block at file offset %d: %w", sectionStartPosition, err)
+			}
 		}
 
 		// update overall progress counters
@@ -110,25 +314,318 @@ func ProcessTAPData(tapData []byte, version byte, clock, sampleRate float64, idx
 
 	} // end main processing loop
 
-	// merge external idx data before returning
-	mergedIndexData := mergeIDXData(indexData, idxEntries)
-	return pcmSamples, mergedIndexData, nil
+	// merge external idx data before returning, adjusting the idx position convention if requested
+	idxOffset := opts.IDXOffset
+	if opts.IDXOffsetAuto && len(idxEntries) > 0 {
+		idxOffset = BestIDXOffset(indexData, idxEntries)
+		fmt.Printf("auto-detected idx offset: %d bytes.\n", idxOffset)
+	}
+	if idxOffset != 0 {
+		idxEntries = idx.ShiftPositions(idxEntries, idxOffset)
+	}
+	return mergeIDXData(indexData, idxEntries), nil
+}
+
+// BestIDXOffset determines which idx position convention fits the detected blocks best: as-is,
+// with the .tap header added, or with it removed. each candidate is merged into a copy of
+// indexData and the one tagging the most blocks wins; ties prefer the unshifted positions.
+func BestIDXOffset(indexData []IndexEntry, idxEntries []idx.IDXEntry) int {
+	bestOffset, bestMatches := 0, -1
+	for _, offset := range []int{0, constants.TapHeaderSize, -constants.TapHeaderSize} {
+		trial := make([]IndexEntry, len(indexData))
+		copy(trial, indexData)
+
+		matches := 0
+		for _, entry := range mergeIDXData(trial, idx.ShiftPositions(idxEntries, offset)) {
+			if entry.IDXTag != "" {
+				matches++
+			}
+		}
+		if matches > bestMatches {
+			bestOffset, bestMatches = offset, matches
+		}
+	}
+	return bestOffset
+}
+
+// PadToWholeSecond appends pause pattern samples to pcmSamples until the total length is a
+// multiple of sampleRate (some hardware tape writers expect whole seconds of audio).
+// the padding only extends the run-off: a trailing pause entry is lengthened, otherwise a new
+// pause entry is appended, so no misleading data block is created.
+// the padded entry consumes no tap bytes, hence its positions stay at the end of the file.
+// the padding follows the pause style of opts (see _generatePause).
+func PadToWholeSecond(pcmSamples []byte, indexData []IndexEntry, sampleRate int, opts ProcessOptions) ([]byte, []IndexEntry) {
+	if sampleRate <= 0 || len(pcmSamples)%sampleRate == 0 {
+		return pcmSamples, indexData // nothing to pad
+	}
+
+	padLen := sampleRate - len(pcmSamples)%sampleRate
+	startSample := len(pcmSamples)
+	pcmSamples = append(pcmSamples, opts.pause(padLen, float64(sampleRate))...)
+
+	// extend a trailing pause, or append a new pause entry after the last block
+	if n := len(indexData); n > 0 && indexData[n-1].Type == "pause" {
+		indexData[n-1].EndSample = len(pcmSamples) - 1
+	} else {
+		endPosition := constants.TapHeaderSize - 1 // no blocks: position right after the header
+		if n > 0 {
+			endPosition = indexData[n-1].EndPosition
+		}
+		indexData = append(indexData, IndexEntry{
+			StartSample:   startSample,
+			EndSample:     len(pcmSamples) - 1,
+			Type:          "pause",
+			StartTime:     float64(startSample) / float64(sampleRate),
+			StartPosition: endPosition + 1,
+			EndPosition:   endPosition, // zero tap bytes consumed
+		})
+	}
+
+	return pcmSamples, indexData
+}
+
+// PrependPause inserts pauseSamples pause pattern samples at the start of pcmSamples (a leader
+// for the tape deck motor to stabilise) and shifts all index entries accordingly. the leader is
+// recorded as a pause entry consuming no tap bytes, so no leading block is created.
+// the leader follows the pause style of opts (see _generatePause).
+func PrependPause(pcmSamples []byte, indexData []IndexEntry, sampleRate int, pauseSamples int, opts ProcessOptions) ([]byte, []IndexEntry) {
+	if sampleRate <= 0 || pauseSamples <= 0 {
+		return pcmSamples, indexData // nothing to prepend
+	}
+
+	pcmSamples = append(opts.pause(pauseSamples, float64(sampleRate)), pcmSamples...)
+
+	// shift existing entries behind the leader
+	shifted := make([]IndexEntry, 0, len(indexData)+1)
+	shifted = append(shifted, IndexEntry{
+		StartSample:   0,
+		EndSample:     pauseSamples - 1,
+		Type:          "pause",
+		StartTime:     0,
+		StartPosition: constants.TapHeaderSize,
+		EndPosition:   constants.TapHeaderSize - 1, // zero tap bytes consumed
+	})
+	for _, entry := range indexData {
+		entry.StartSample += pauseSamples
+		entry.EndSample += pauseSamples
+		entry.StartTime = float64(entry.StartSample) / float64(sampleRate)
+		shifted = append(shifted, entry)
+	}
+
+	return pcmSamples, shifted
+}
+
+// AppendTape appends the audio and index of another processed tap (nextPCM, nextIndex) behind
+// pcmSamples and indexData, separated by gapSamples pause pattern samples (none before the first
+// tape). the gap is recorded as a pause entry consuming no tap bytes. the byte positions of
+// nextIndex are shifted by positionOffset, e.g. the payload size of the taps before it when
+// their payloads are joined into one tap.
+func AppendTape(pcmSamples []byte, indexData []IndexEntry, nextPCM []byte, nextIndex []IndexEntry, positionOffset int, sampleRate int, gapSamples int, opts ProcessOptions) ([]byte, []IndexEntry) {
+	if sampleRate <= 0 {
+		return pcmSamples, indexData
+	}
+
+	if len(pcmSamples) > 0 && gapSamples > 0 {
+		indexData = append(indexData, IndexEntry{
+			StartSample:   len(pcmSamples),
+			EndSample:     len(pcmSamples) + gapSamples - 1,
+			Type:          "pause",
+			StartTime:     float64(len(pcmSamples)) / float64(sampleRate),
+			StartPosition: constants.TapHeaderSize + positionOffset,
+			EndPosition:   constants.TapHeaderSize + positionOffset - 1, // zero tap bytes consumed
+		})
+		pcmSamples = append(pcmSamples, opts.pause(gapSamples, float64(sampleRate))...)
+	}
+
+	// shift the appended entries behind the existing audio
+	sampleOffset := len(pcmSamples)
+	for _, entry := range nextIndex {
+		entry.StartSample += sampleOffset
+		entry.EndSample += sampleOffset
+		entry.StartTime = float64(entry.StartSample) / float64(sampleRate)
+		entry.StartPosition += positionOffset
+		entry.EndPosition += positionOffset
+		indexData = append(indexData, entry)
+	}
+
+	return append(pcmSamples, nextPCM...), indexData
+}
+
+// LeaderSilence returns the number of samples of blank tape before the first signal, i.e. the
+// total length of the pause entries at the start of indexData. pauses consuming no tap bytes
+// (such as the leader added by PrependPause) are not part of the capture and are skipped.
+func LeaderSilence(indexData []IndexEntry) int {
+	samples := 0
+	for _, entry := range indexData {
+		if entry.Type != "pause" {
+			break
+		}
+		if entry.ByteLength() > 0 {
+			samples += entry.DurationSamples()
+		}
+	}
+	return samples
+}
+
+// TrimLeadingPause removes the pause entries at the start of indexData (the blank tape before
+// the first signal) and their samples from pcmSamples, shifting the remaining entries to the
+// start. it returns the number of samples removed.
+func TrimLeadingPause(pcmSamples []byte, indexData []IndexEntry, sampleRate int) ([]byte, []IndexEntry, int) {
+	n := 0
+	for n < len(indexData) && indexData[n].Type == "pause" {
+		n++
+	}
+	if n == 0 || n == len(indexData) || sampleRate <= 0 {
+		return pcmSamples, indexData, 0 // no leader, or nothing but pauses
+	}
+
+	trimSamples := min(indexData[n].StartSample, len(pcmSamples))
+	trimmed := make([]IndexEntry, 0, len(indexData)-n)
+	for _, entry := range indexData[n:] {
+		entry.StartSample -= trimSamples
+		entry.EndSample -= trimSamples
+		entry.StartTime = float64(entry.StartSample) / float64(sampleRate)
+		trimmed = append(trimmed, entry)
+	}
+	return pcmSamples[trimSamples:], trimmed, trimSamples
+}
+
+// MergeAdjacentBlocks merges consecutive lead or data entries of the same type that directly
+// follow each other (no intervening pause) into a single entry, extending its EndSample and
+// EndPosition. pauses are never merged, nor are entries of different types. the first entry's
+// idx tag is kept; if it has none, the tag of a merged entry is used. the audio is not changed.
+func MergeAdjacentBlocks(indexData []IndexEntry) []IndexEntry {
+	if len(indexData) < 2 {
+		return indexData
+	}
+
+	merged := make([]IndexEntry, 0, len(indexData))
+	for _, entry := range indexData {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			// only merge contiguous non-pause entries of the same type
+			if entry.Type != "pause" && entry.Type == prev.Type &&
+				entry.StartSample == prev.EndSample+1 && entry.StartPosition == prev.EndPosition+1 {
+				prev.EndSample = entry.EndSample
+				prev.EndPosition = entry.EndPosition
+				if prev.IDXTag == "" {
+					prev.IDXTag = entry.IDXTag
+				}
+				continue
+			}
+		}
+		merged = append(merged, entry)
+	}
+
+	return merged
+}
+
+// DropShortBlocks removes lead and data entries shorter than minSamples samples from indexData,
+// so tiny spurious blocks (e.g. from noise) are not exported. pauses are kept and the audio is
+// not changed. returns the filtered index and the number of dropped entries.
+func DropShortBlocks(indexData []IndexEntry, minSamples int) ([]IndexEntry, int) {
+	if minSamples <= 0 {
+		return indexData, 0
+	}
+
+	kept := make([]IndexEntry, 0, len(indexData))
+	for _, entry := range indexData {
+		if entry.Type != "pause" && entry.DurationSamples() < minSamples {
+			fmt.Printf("warning: dropping %s block at file offset 0x%x (%d samples, minimum %d).\n",
+				entry.Type, entry.StartPosition, entry.DurationSamples(), minSamples)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	return kept, len(indexData) - len(kept)
+}
+
+// FilterByName keeps only the entries of the programs whose idx tag matches pattern, so a single
+// title can be exported from a compilation. a program starts at a tagged lead or data entry and
+// spans all following untagged entries (e.g. its data blocks and pauses) up to the next tagged
+// one; entries before the first tag belong to no program. pattern is a glob if it contains any of
+// *?[ and a substring otherwise, both matched case-insensitively against the whole tag.
+// the audio is not changed. returns the filtered index and the number of dropped blocks.
+func FilterByName(indexData []IndexEntry, pattern string) ([]IndexEntry, int, error) {
+	isGlob := strings.ContainsAny(pattern, "*?[")
+	if isGlob {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, 0, fmt.Errorf("invalid name pattern '%s': %w", pattern, err)
+		}
+	}
+	pattern = strings.ToLower(pattern)
+
+	kept := make([]IndexEntry, 0, len(indexData))
+	keep := false // whether the current program matches
+	dropped := 0
+	for _, entry := range indexData {
+		if entry.Type != "pause" && entry.IDXTag != "" {
+			tag := strings.ToLower(entry.IDXTag)
+			if isGlob {
+				keep, _ = path.Match(pattern, tag) // pattern already validated
+			} else {
+				keep = strings.Contains(tag, pattern)
+			}
+		}
+		if !keep {
+			if entry.Type != "pause" {
+				dropped++
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	return kept, dropped, nil
 }
 
 // mergeIDXData assigns tags from an external .idx file (idxEntries) to detected blocks (indexData).
 // for each idxEntry, it finds the most appropriate block in indexData by comparing the idxEntry's
 // byte Position to the block's StartPosition (relative to the original .tap file). a match is
 // considered appropriate if the positions are within maxOffset bytes of each other.
+// if several idx entries match the same block, the one with the highest position wins
+// (ties keep the later entry), so the result is deterministic for any idx ordering.
 func mergeIDXData(indexData []IndexEntry, idxEntries []idx.IDXEntry) []IndexEntry {
 	// skip if nothing to merge (no .idx file with entries)
 	if len(idxEntries) == 0 || len(indexData) == 0 {
 		return indexData
 	}
 
+	// find the matching block for every idx entry and assign its tag
+	// (later entries overwrite earlier ones matching the same block)
+	for j, bestMatchIdx := range _matchIDXEntries(indexData, idxEntries, constants.MaxOffset) {
+		if bestMatchIdx != -1 {
+			indexData[bestMatchIdx].IDXTag = idxEntries[j].Name
+		}
+	}
+
+	// final sort of indexData to ensure canonical order before returning.
+	// most likely not needed - but cheap, so why not.
+	sort.Slice(indexData, func(i, j int) bool {
+		if indexData[i].StartPosition != indexData[j].StartPosition {
+			return indexData[i].StartPosition < indexData[j].StartPosition
+		}
+		// 2nd sort by end position if start is the same
+		if indexData[i].EndPosition != indexData[j].EndPosition {
+			return indexData[i].EndPosition < indexData[j].EndPosition
+		}
+		// 3rd tertiary sort by start time if positions are the same
+		return indexData[i].StartTime < indexData[j].StartTime
+	})
+
+	return indexData
+}
+
+// _matchIDXEntries sorts indexData and idxEntries by position (in place) and returns, for every
+// idx entry, the index of its best matching "data" or "lead" block in indexData, or -1 if no
+// block starts within tolerance bytes of its position. this is the matching rule of mergeIDXData.
+func _matchIDXEntries(indexData []IndexEntry, idxEntries []idx.IDXEntry, tolerance int) []int {
 	// sort both slices by position for efficient matching
 	sort.Slice(indexData, func(i, j int) bool { return indexData[i].StartPosition < indexData[j].StartPosition })
-	sort.Slice(idxEntries, func(i, j int) bool { return idxEntries[i].Position < idxEntries[j].Position })
+	sort.SliceStable(idxEntries, func(i, j int) bool { return idxEntries[i].Position < idxEntries[j].Position }) // stable: keep file order for equal positions
 
+	matches := make([]int, len(idxEntries))
 	k := 0 // index for indexData slice
 
 	// iterate through external .idx entries
@@ -136,17 +633,17 @@ func mergeIDXData(indexData []IndexEntry, idxEntries []idx.IDXEntry) []IndexEntr
 		idxEntry := idxEntries[j]
 		targetPos := idxEntry.Position // position from .idx file
 
-		// define the search window around the target position using maxOffset
-		minPos := targetPos - constants.MaxOffset
-		maxPos := targetPos + constants.MaxOffset
+		// define the search window around the target position using tolerance
+		minPos := targetPos - tolerance
+		maxPos := targetPos + tolerance
 
 		// advance indexData pointer (k) past entries that are definitely too early
 		for k < len(indexData) && indexData[k].EndPosition < minPos {
 			k++
 		}
 
-		bestMatchIdx := -1                     // index in indexData of the best match found
-		minDistance := constants.MaxOffset + 1 // track closest distance found so far
+		bestMatchIdx := -1           // index in indexData of the best match found
+		minDistance := tolerance + 1 // track closest distance found so far
 
 		// search for the best match within the window [minPos, maxPos]
 		// iterate starting from k (we don't need to re-check earlier entries)
@@ -167,41 +664,108 @@ func mergeIDXData(indexData []IndexEntry, idxEntries []idx.IDXEntry) []IndexEntr
 				distance := abs(targetPos - indexEntryToTest.StartPosition)
 
 				// if within tolerance and closer than previous best match, update best match
-				if distance <= constants.MaxOffset && distance < minDistance {
+				if distance <= tolerance && distance < minDistance {
 					minDistance = distance
 					bestMatchIdx = currentK
 				}
 			}
 		} // end inner search loop (for currentK)
 
-		// if a suitable match was found, assign the tag
-		if bestMatchIdx != -1 {
-			indexData[bestMatchIdx].IDXTag = idxEntry.Name
-		}
+		matches[j] = bestMatchIdx
 	} // end outer loop (for j)
 
-	// final sort of indexData to ensure canonical order before returning.
-	// most likely not needed - but cheap, so why not.
-	sort.Slice(indexData, func(i, j int) bool {
-		if indexData[i].StartPosition != indexData[j].StartPosition {
-			return indexData[i].StartPosition < indexData[j].StartPosition
+	return matches
+}
+
+// _estimateSamples sums the samples of all pulses and pauses in tapData without generating
+// them: every non-zero byte is a pulse of byte*cyclesPerUnit cycles and every 0x00 sequence
+// (pause or long pulse) its overflow cycles, split into blocks and converted like the generator
+// does, so the result matches the generated length. it stops at a malformed pause, where
+// processing fails anyway.
+func _estimateSamples(tapData []byte, version byte, clock, sampleRate float64, opts ProcessOptions) int {
+	samples := 0
+	for i := constants.TapHeaderSize; i < len(tapData); {
+		if tapData[i] == 0 && !_startsWithLongPulse(tapData, i, version, opts) {
+			n, cycles, err := _pauseCycles(tapData, i, version)
+			if err != nil {
+				break
+			}
+			samples += cyclesToSamples(cycles, clock, sampleRate, opts.Rounding)
+			i += n
+			continue
 		}
-		// 2nd sort by end position if start is the same
-		if indexData[i].EndPosition != indexData[j].EndPosition {
-			return indexData[i].EndPosition < indexData[j].EndPosition
+		// data or lead block, up to the next pause (see _processDataLeadBlock)
+		sampler := _newPulseSampler(version, clock, sampleRate, opts.Rounding)
+		for i < len(tapData) {
+			n, cycles := 1, uint32(tapData[i])*opts.cyclesPerUnit()
+			if tapData[i] == 0 {
+				var err error
+				if n, cycles, err = _pauseCycles(tapData, i, version); err != nil || !opts.isLongPulse(version, cycles) {
+					break
+				}
+			}
+			pulseSamples, _ := sampler.samples(cycles)
+			samples += pulseSamples
+			i += n
 		}
-		// 3rd tertiary sort by start time if positions are the same
-		return indexData[i].StartTime < indexData[j].StartTime
-	})
+	}
+	return samples
+}
 
-	return indexData
+// _pulseSampler converts the pulses of one data or lead block into sample counts. in v2 the
+// high and low half-waves of a cycle are rounded together: the low half gets the samples of
+// the whole cycle minus those of the high half, so a cycle takes as many samples as the
+// equivalent v1 pulse instead of losing up to a sample to the rounding of each half.
+type _pulseSampler struct {
+	clock, sampleRate float64
+	rounding          RoundingMode
+	halfWave          bool   // v2: pulses are half-waves
+	high              bool   // v2: the next half-wave is the high one
+	highCycles        uint32 // v2: cycles of the preceding high half-wave
+	highSamples       int    // v2: samples of the preceding high half-wave
+}
+
+// _newPulseSampler returns a sampler for a block starting with a high half-wave in v2.
+func _newPulseSampler(version byte, clock, sampleRate float64, rounding RoundingMode) *_pulseSampler {
+	return &_pulseSampler{clock: clock, sampleRate: sampleRate, rounding: rounding, halfWave: version == constants.TapVersionHalfWave, high: true}
+}
+
+// samples returns the number of samples of the next pulse of cycles cycles and, in v2,
+// whether it is a high half-wave.
+func (s *_pulseSampler) samples(cycles uint32) (n int, high bool) {
+	n = cyclesToSamples(cycles, s.clock, s.sampleRate, s.rounding)
+	if !s.halfWave {
+		return n, false
+	}
+	high = s.high
+	s.high = !s.high
+	if high {
+		s.highCycles, s.highSamples = cycles, n
+		return n, true
+	}
+	return cyclesToSamples(s.highCycles+cycles, s.clock, s.sampleRate, s.rounding) - s.highSamples, false
 }
 
 // _processPauseBlock handles a tap pause block (identified by starting byte value 0).
 // determines duration based on tap version and following bytes and aims to correctly
 // process and interpret how both v0 and v1 .tap formats represent pauses (silence),
 // while also handling incomplete or truncated files gracefully where possible.
-func _processPauseBlock(tapData []byte, i int, version byte, clock, sampleRate float64) (pcm []byte, bytesRead int, cycles uint32, err error) {
+func _processPauseBlock(tapData []byte, i int, version byte, clock, sampleRate float64, opts ProcessOptions) (pcm []byte, bytesRead int, cycles uint32, err error) {
+	// determine pause duration (in cycles) and bytes consumed
+	bytesRead, cycles, err = _pauseCycles(tapData, i, version)
+	if err != nil {
+		return // return immediately with error
+	}
+
+	// generate audio samples for the pause
+	pauseSamples := cyclesToSamples(cycles, clock, sampleRate, opts.Rounding)
+	pcm = opts.pause(pauseSamples, sampleRate) // use helper to generate silent samples
+	return pcm, bytesRead, cycles, nil         // return generated pcm, bytes consumed, cycles, and nil error
+}
+
+// _pauseCycles determines the duration (in cycles) of the pause starting at tapData[i]
+// and the number of tap bytes it occupies, based on the tap version.
+func _pauseCycles(tapData []byte, i int, version byte) (bytesRead int, cycles uint32, err error) {
 	bytesRead = 1 // start with the '0' byte itself
 	pauseDurationOffset := i + bytesRead
 
@@ -211,8 +775,8 @@ func _processPauseBlock(tapData []byte, i int, version byte, clock, sampleRate f
 			cycles = 20000 // default pause for v0 if duration bytes are missing (spec is unclear here)
 			// bytesRead remains 1, effectively consuming only the '0'
 		} else {
-			// v1 requires 3 bytes for duration, hitting EOF is an error
-			err = fmt.Errorf("unexpected EOF reading v1 pause duration at offset %d", i)
+			// v1/v2 require 3 bytes for duration, hitting EOF is an error
+			err = fmt.Errorf("unexpected EOF reading v%d pause duration at offset %d", version, i)
 			return // return immediately with error
 		}
 	} else {
@@ -222,48 +786,65 @@ func _processPauseBlock(tapData []byte, i int, version byte, clock, sampleRate f
 			// these 3 bytes must still be consumed to advance 'i' correctly in the main loop.
 			bytesRead += 3
 		} else {
-			// v1 reads 3 bytes for duration
+			// v1 reads 3 bytes for duration. in v2 they hold the duration of a single
+			// half-wave, which for a pause is its whole (silent) length just the same.
 			cycles = uint32(tapData[pauseDurationOffset]) | (uint32(tapData[pauseDurationOffset+1]) << 8) | (uint32(tapData[pauseDurationOffset+2]) << 16)
 			bytesRead += 3 // consume the 3 duration bytes
 		}
 	}
 
-	// generate audio samples for the pause
-	pauseSamples := cyclesToSamples(cycles, clock, sampleRate)
-	pcm = _generatePause(pauseSamples) // use helper to generate silent samples
-	return pcm, bytesRead, cycles, nil // return generated pcm, bytes consumed, cycles, and nil error
+	return bytesRead, cycles, nil
 }
 
 // _processDataLeadBlock handles a sequence of non-zero tap bytes, treating it as pulses.
 // it also determines if the sequence likely constitutes a leader tone.
-func _processDataLeadBlock(tapData []byte, i int, clock, sampleRate float64) (pcm []byte, isLead bool, bytesRead int, totalCycles uint32, err error) {
+// with opts.LongPulseMaxCycles set, short v1 overflow sequences (0x00 + 3-byte cycle count)
+// are emitted as one long pulse within the block instead of ending it.
+// in v2 every byte (or long pulse) is a half-wave: two consecutive ones form a full cycle with
+// independent high and low durations, starting with a high half at the start of the block
+// (rounded together, see _pulseSampler).
+func _processDataLeadBlock(tapData []byte, i int, version byte, clock, sampleRate float64, opts ProcessOptions) (pcm []byte, isLead bool, bytesRead int, totalCycles uint32, err error) {
 	startOffset := i // remember starting position for lead tone check and error messages
 
 	// check if this block qualifies as a leader tone right from the start
-	isLead = isLeadTone(tapData, startOffset)
+	isLead = isLeadTone(tapData, startOffset, opts.LeadPulseValue, opts.LeadPulseTolerance, opts.LeadGlitches)
 
 	// pre-allocate pcm slice (estimate capacity)
 	pcm = make([]byte, 0, 1024) // initial capacity, will grow as needed
 
+	halfWave := version == constants.TapVersionHalfWave
+	sampler := _newPulseSampler(version, clock, sampleRate, opts.Rounding)
+
 	// loop through consecutive non-zero bytes
 	for i < len(tapData) {
 		b := tapData[i]
+		pulseBytes := 1
+		// convert tap byte value to cpu cycles (each unit is 8 cycles unless overridden)
+		pulseCycles := uint32(b) * opts.cyclesPerUnit()
 		if b == 0 {
-			break // zero byte marks end of data/lead block, start of pause
+			// zero byte marks end of data/lead block, start of pause - unless it's a long pulse
+			n, cycles, pauseErr := _pauseCycles(tapData, i, version)
+			if pauseErr != nil || !opts.isLongPulse(version, cycles) {
+				break
+			}
+			pulseBytes, pulseCycles = n, cycles
 		}
 
-		// convert tap byte value to cpu cycles (each unit is 8 cycles)
-		pulseCycles := uint32(b) * 8
 		// convert cycles to number of audio samples
-		waveSamples := cyclesToSamples(pulseCycles, clock, sampleRate)
-		// generate the square wave for this pulse
-		waveData := generateWave(waveSamples, 127) // use max amplitude (127)
+		waveSamples, highHalf := sampler.samples(pulseCycles)
+		// generate the square wave for this pulse (or one half of it in v2)
+		var waveData []byte
+		if halfWave {
+			waveData = opts.applyPolarity(_generateHalfWave(waveSamples, highHalf, opts.amplitude(), opts.EdgeRamp, opts.Waveform))
+		} else {
+			waveData = opts.applyPolarity(generateWave(waveSamples, opts.amplitude(), opts.EdgeRamp, opts.Waveform))
+		}
 		// append generated wave to the block's pcm data
 		pcm = append(pcm, waveData...)
 
 		totalCycles += pulseCycles // accumulate total cycles for potential use
-		bytesRead++                // increment count of tap bytes consumed
-		i++                        // advance index in tapData
+		bytesRead += pulseBytes    // increment count of tap bytes consumed
+		i += pulseBytes            // advance index in tapData
 	}
 
 	// check if any data bytes were actually read
@@ -276,25 +857,60 @@ func _processDataLeadBlock(tapData []byte, i int, clock, sampleRate float64) (pc
 	return pcm, isLead, bytesRead, totalCycles, err
 }
 
+// _startsWithLongPulse reports whether the 0x00 overflow sequence at i is short enough to be a
+// long pulse (see ProcessOptions.isLongPulse) and directly followed by a pulse, so it belongs
+// to the data block it precedes rather than forming a pause of its own.
+func _startsWithLongPulse(tapData []byte, i int, version byte, opts ProcessOptions) bool {
+	n, cycles, err := _pauseCycles(tapData, i, version)
+	if err != nil || !opts.isLongPulse(version, cycles) {
+		return false
+	}
+	return i+n < len(tapData) && tapData[i+n] != 0
+}
+
 // _generatePause generates samples for pause durations using a specific 255/1 pattern
 // (one pulse: half high, half low) for the entire pause length.
 // note: this pattern deviates from true silence (value 128).
 // rationale: this specific pattern is used intentionally because testing showed that
 // the abrupt transitions resulting from starting/stopping true silence (128) can
 // cause critical loading failures - example: end of P.O.D - Proof of Destruction.
-func _generatePause(len int) []byte {
+// trueSilence (off by default for that reason) emits constant 128 instead, e.g. for waveform analysis.
+// a tonePeriod > 0 (in samples) repeats the pattern as a steady carrier instead, for hardware that
+// prefers a tone during gaps. it has more transitions than the single pulse, so the single
+// pulse stays the default as well.
+// amp scales the pattern to 128+amp/128-amp like the pulses; the full 127 gives 255/1.
+func _generatePause(len int, trueSilence bool, tonePeriod int, amp byte) []byte {
 	samples := make([]byte, len)
-	// fill first half with high value (255), second half with low value (1)
+	if trueSilence {
+		for i := range samples {
+			samples[i] = 128 // centre value, becomes 0 in signed 16-bit output
+		}
+		return samples
+	}
+	period := len // one pulse over the whole pause by default
+	if tonePeriod > 0 {
+		period = max(tonePeriod, 2)
+	}
+	// fill first half of each period with high value (255), second half with low value (1)
 	for i := range samples { // use range for idiomatic slice loop
-		if i < len/2 {
-			samples[i] = 255
+		if i%period < period/2 {
+			samples[i] = 128 + amp
 		} else {
-			samples[i] = 1
+			samples[i] = 128 - amp
 		}
 	}
 	return samples
 }
 
+// pause generates len pause samples in the style selected by the options.
+func (o ProcessOptions) pause(len int, sampleRate float64) []byte {
+	tonePeriod := 0
+	if o.PauseToneHz > 0 {
+		tonePeriod = int(math.Round(sampleRate / float64(o.PauseToneHz)))
+	}
+	return o.applyPolarity(_generatePause(len, o.TrueSilence, tonePeriod, o.amplitude()))
+}
+
 // abs returns the absolute value of the integer x.
 // note: needed because standard library math.Abs operates on float64.
 func abs(x int) int {
@@ -306,7 +922,7 @@ func abs(x int) int {
 
 // cyclesToSamples converts a duration measured in c64 cpu cycles into the
 // corresponding number of audio samples at the given sample rate.
-func cyclesToSamples(cycles uint32, clock, sampleRate float64) int {
+func cyclesToSamples(cycles uint32, clock, sampleRate float64, rounding RoundingMode) int {
 	// calculation logic:
 	// 1. determine duration in seconds: time_sec = cycles / clock_hz
 	// 2. determine samples needed: samples = time_sec * sample_rate_hz
@@ -315,25 +931,47 @@ func cyclesToSamples(cycles uint32, clock, sampleRate float64) int {
 	// perform calculation using float64 for precision.
 	numSamplesFloat := float64(cycles) * sampleRate / clock
 
-	// use math.Floor to round down, ensuring generated audio doesn't exceed
+	// by default use math.Floor to round down, ensuring generated audio doesn't exceed
 	// original duration. convert to int because we need a whole number of samples.
+	switch rounding {
+	case RoundNearest:
+		return int(math.Round(numSamplesFloat))
+	case RoundCeil:
+		return int(math.Ceil(numSamplesFloat))
+	}
 	return int(math.Floor(numSamplesFloat))
 }
 
-// generateWave creates a square wave for tape pulses.
+// generateWave creates a square wave for tape pulses (or a sine or triangle cycle, see Waveform).
 // 'len' is number of samples, 'amp' is amplitude (0-127).
-func generateWave(len int, amp byte) []byte {
+// 'ramp' softens the rising (start) and falling (middle) edge of the square wave with a linear
+// ramp of that many samples, reducing aliasing; it is capped to a quarter of each half so short
+// pulses keep their shape. the number of samples is never changed.
+func generateWave(len int, amp byte, ramp int, waveform Waveform) []byte {
+	if waveform == WaveSine || waveform == WaveTriangle {
+		return _generateCycle(len, amp, waveform)
+	}
+
 	samples := make([]byte, len)
 	offset := byte(128) // dc offset for unsigned 8-bit audio
 	halfLen := len / 2
+	ramp = min(ramp, halfLen/4)
 
 	// create a square wave: high for first half, low for second half
 	for i := 0; i < len; i++ {
 		var y float64
 		if i < halfLen {
 			y = float64(amp) + float64(offset) // positive amplitude + offset
+			if i < ramp {
+				// rising edge from low to high
+				y = -float64(amp) + 2*float64(amp)*float64(i+1)/float64(ramp+1) + float64(offset)
+			}
 		} else {
 			y = -float64(amp) + float64(offset) // negative amplitude + offset
+			if i-halfLen < ramp {
+				// falling edge from high to low
+				y = float64(amp) - 2*float64(amp)*float64(i-halfLen+1)/float64(ramp+1) + float64(offset)
+			}
 		}
 		// clamp value to valid 8-bit range [0, 255]
 		samples[i] = byte(math.Max(0, math.Min(255, y)))
@@ -341,37 +979,99 @@ func generateWave(len int, amp byte) []byte {
 	return samples
 }
 
+// _generateHalfWave creates the high (first) or low (second) half of a pulse of twice len
+// samples, so a v2 half-wave keeps the shape and edges of the selected waveform while its
+// duration is independent of the other half.
+func _generateHalfWave(len int, high bool, amp byte, ramp int, waveform Waveform) []byte {
+	wave := generateWave(2*len, amp, ramp, waveform)
+	if high {
+		return wave[:len]
+	}
+	return wave[len:]
+}
+
+// _generateCycle creates one full sine or triangle cycle of len samples with amplitude amp
+// around the 128 dc offset: rising from the dc level to +amp, down to -amp and back, so the
+// first half of the pulse lies above and the second half below the dc level like a square wave.
+// samples are taken at the centre of each sample period.
+func _generateCycle(len int, amp byte, waveform Waveform) []byte {
+	samples := make([]byte, len)
+	for i := range samples {
+		phase := (float64(i) + 0.5) / float64(len) // 0..1 over the pulse
+		var y float64
+		if waveform == WaveSine {
+			y = math.Sin(2 * math.Pi * phase)
+		} else {
+			// triangle: 0 -> 1 at a quarter, -1 at three quarters, back to 0
+			y = 1 - 4*math.Abs(phase-0.25)
+			if phase > 0.75 {
+				y = 4*phase - 4
+			}
+		}
+		// clamp value to valid 8-bit range [0, 255]
+		samples[i] = byte(math.Max(0, math.Min(255, math.Round(128+float64(amp)*y))))
+	}
+	return samples
+}
+
 // isLeadTone checks if data starting at startPos looks like a c64 lead/header tone
 // (beeeeeeeeeeeeeeeeeep). It requires a non-zero starting byte (not a pause) and
-// verifies that a sequence of consecutive bytes matching the starting byte's value
-// is both long enough (minLeadToneLength) and consistent enough (requiredConsistency)
-// within the available data.
-func isLeadTone(tapData []byte, startPos int) bool {
+// scans a window of minLeadToneLength bytes (up to the next pause), of which at least
+// requiredConsistency must match the starting byte's value, so single glitch bytes of a
+// real capture do not end the lead. glitches > 0 additionally caps the number of
+// non-matching bytes within the window.
+// if leadValue is non-zero, bytes must instead lie within tolerance of leadValue (the
+// expected pilot pulse width), so runs of identical data bytes are not mistaken for a lead.
+func isLeadTone(tapData []byte, startPos int, leadValue, tolerance byte, glitches int) bool {
+	isLead, _ := _explainLeadTone(tapData, startPos, leadValue, tolerance, glitches)
+	return isLead
+}
+
+// _explainLeadTone implements isLeadTone, additionally returning the first failing check
+// as a human readable reason ("" if the data qualifies as a lead tone).
+func _explainLeadTone(tapData []byte, startPos int, leadValue, tolerance byte, glitches int) (bool, string) {
 	// check if there's enough data left for minLeadToneLength requirement
 	if startPos+int(constants.MinLeadToneLength) > len(tapData) {
-		return false
+		return false, fmt.Sprintf("only %d bytes left, a lead needs at least %d", len(tapData)-startPos, constants.MinLeadToneLength)
 	}
 
 	// leader tone cannot be represented by 0 bytes (which indicate pauses)
 	candidateValue := tapData[startPos]
 	if candidateValue == 0 {
-		return false
+		return false, "starts with an overflow byte (0x00), not a pilot pulse"
+	}
+
+	// a byte matches the lead if it equals the starting byte, or - with an expected
+	// pilot value set - if it lies within tolerance of that value.
+	matches := func(b byte) bool { return b == candidateValue }
+	if leadValue != 0 {
+		matches = func(b byte) bool { return abs(int(b)-int(leadValue)) <= int(tolerance) }
+		if !matches(candidateValue) {
+			return false, fmt.Sprintf("first pulse 0x%02x outside pilot value 0x%02x +/- 0x%02x", candidateValue, leadValue, tolerance)
+		}
 	}
 
 	sameValueCount := 0
 	// determine how many bytes to check - either up to minLeadToneLength or end of data
 	checkLength := min(len(tapData)-startPos, int(constants.MinLeadToneLength))
 
-	// count consecutive bytes matching the first byte's value
+	// count the bytes matching the first byte's value (or the expected pilot value) over the
+	// whole window. an overflow byte (a pause) ends the block and with it the scan.
+	runEnd := checkLength
+	mismatches := 0
 	for j := 0; j < checkLength; j++ {
-		// check if the current byte matches the first byte of the sequence
-		if tapData[startPos+j] == candidateValue {
+		if b := tapData[startPos+j]; matches(b) {
 			sameValueCount++
-		} else {
-			// assumes tone is contiguous identical bytes. stop counting if mismatch found.
+		} else if b == 0 {
+			runEnd = j
 			break
+		} else {
+			mismatches++
 		}
 	}
+	if glitches > 0 && mismatches > glitches {
+		return false, fmt.Sprintf("%d non-matching bytes within the pilot window up to offset %d (at most %d allowed)", mismatches, startPos+runEnd, glitches)
+	}
 
 	// calculate consistency if any matching bytes were found
 	if sameValueCount > 0 {
@@ -379,14 +1079,21 @@ func isLeadTone(tapData []byte, startPos int) bool {
 		denominator := float64(checkLength) // use the actual number of bytes checked
 		if denominator == 0 {
 			// prevent division by zero if checkLength somehow ended up 0
-			return false
+			return false, "no bytes to check"
 		}
 		consistency := float64(sameValueCount) / denominator
 
 		// check requires both high consistency and that we examined at least the minimum length.
-		return consistency >= constants.RequiredConsistency && checkLength >= int(constants.MinLeadToneLength)
+		if consistency < constants.RequiredConsistency {
+			return false, fmt.Sprintf("pilot window ends at offset %d with %d of %d bytes matching (consistency %.2f, need %.2f)",
+				startPos+runEnd, sameValueCount, checkLength, consistency, constants.RequiredConsistency)
+		}
+		if checkLength < int(constants.MinLeadToneLength) {
+			return false, fmt.Sprintf("checked only %d bytes, a lead needs at least %d", checkLength, constants.MinLeadToneLength)
+		}
+		return true, ""
 	}
 
 	// return false if no matching bytes were found (e.g., if checkLength was 0)
-	return false
+	return false, "no matching pilot bytes"
 }
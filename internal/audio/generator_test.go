@@ -0,0 +1,383 @@
+// internal/audio/generator_test.go
+
+package audio
+
+import (
+	"bytes"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/idx"
+	"go_chirp_the_tap/internal/testutil"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// benchmarkTapes are synthetic tapes of increasing size (files, data pulses per file).
+var benchmarkTapes = []struct {
+	name             string
+	files, dataBytes int
+}{
+	{"small", 1, 2000},
+	{"medium", 4, 20000},
+	{"large", 16, 50000},
+}
+
+func BenchmarkProcessTAPData(b *testing.B) {
+	testutil.Quiet(b)
+	for _, tape := range benchmarkTapes {
+		tapData := testutil.TAP(1, testutil.MultiBlockPayload(tape.files, tape.dataBytes))
+		b.Run(tape.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tapData)))
+			for range b.N {
+				if _, _, err := ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamTAPDataUnsized streams into a buffer that grows as needed, the way
+// ProcessTAPData collected samples before presizing its buffer with _estimateSamples. compare
+// its B/op with BenchmarkProcessTAPData: the regrown copies of the buffer add about a third
+// (the allocation count is dominated by the per-pulse waves and barely changes).
+func BenchmarkStreamTAPDataUnsized(b *testing.B) {
+	testutil.Quiet(b)
+	for _, tape := range benchmarkTapes {
+		tapData := testutil.TAP(1, testutil.MultiBlockPayload(tape.files, tape.dataBytes))
+		b.Run(tape.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tapData)))
+			for range b.N {
+				var pcm bytes.Buffer
+				if _, err := StreamTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{}, &pcm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateSamplesMatchesOutput(t *testing.T) {
+	testutil.Quiet(t)
+	payload := testutil.MultiBlockPayload(2, 501)
+	tapes := []struct {
+		version byte
+		payload []byte
+	}{
+		{0, payload},
+		{1, payload},
+		{2, _halfWavePayload(payload)},
+	}
+	options := map[string]ProcessOptions{
+		"default":      {},
+		"true silence": {TrueSilence: true},
+		"pause tone":   {PauseToneHz: 2000},
+		"long pulses":  {LongPulseMaxCycles: 30000},
+		"nearest":      {Rounding: RoundNearest},
+	}
+	for _, tape := range tapes {
+		tapData := testutil.TAP(tape.version, tape.payload)
+		for name, opts := range options {
+			pcm, _, err := ProcessTAPData(tapData, tape.version, constants.ClockPAL, constants.SampleRate, nil, opts)
+			if err != nil {
+				t.Fatalf("v%d %s: %v", tape.version, name, err)
+			}
+			if estimate := _estimateSamples(tapData, tape.version, constants.ClockPAL, constants.SampleRate, opts); estimate != len(pcm) {
+				t.Errorf("v%d %s: estimate %d samples, generated %d", tape.version, name, estimate, len(pcm))
+			}
+		}
+	}
+}
+
+func TestMergeIDXDataDuplicates(t *testing.T) {
+	index := func() []IndexEntry {
+		return []IndexEntry{
+			{Type: "lead", StartPosition: 20, EndPosition: 999},
+			{Type: "pause", StartPosition: 1000, EndPosition: 1003},
+			{Type: "data", StartPosition: 5000, EndPosition: 5999},
+		}
+	}
+	// entries matching the same block (same or nearby positions): the one last in position
+	// order wins, the file order decides between equal positions
+	idxEntries := []idx.IDXEntry{{Position: 5010, Name: "LATE"}, {Position: 20, Name: "A"}, {Position: 4990, Name: "EARLY"}, {Position: 20, Name: "B"}}
+	for run := 0; run < 3; run++ {
+		merged := mergeIDXData(index(), append([]idx.IDXEntry(nil), idxEntries...))
+		if merged[0].IDXTag != "B" || merged[1].IDXTag != "" || merged[2].IDXTag != "LATE" {
+			t.Fatalf("run %d: tags %q, %q, %q; want B, none, LATE", run, merged[0].IDXTag, merged[1].IDXTag, merged[2].IDXTag)
+		}
+	}
+}
+
+func TestCyclesToSamplesRounding(t *testing.T) {
+	// at pal clock and 44100 hz: 384 cycles are 17.19 samples, 1000 cycles 44.76 samples
+	tests := []struct {
+		cycles   uint32
+		rounding RoundingMode
+		want     int
+	}{
+		{384, "", 17},
+		{384, RoundFloor, 17},
+		{384, RoundNearest, 17},
+		{384, RoundCeil, 18},
+		{1000, RoundFloor, 44},
+		{1000, RoundNearest, 45},
+		{1000, RoundCeil, 45},
+		{0, RoundCeil, 0},
+	}
+	for _, tt := range tests {
+		if got := cyclesToSamples(tt.cycles, constants.ClockPAL, constants.SampleRate, tt.rounding); got != tt.want {
+			t.Errorf("cyclesToSamples(%d, %q) = %d, want %d", tt.cycles, tt.rounding, got, tt.want)
+		}
+	}
+
+	for _, name := range []string{"floor", "round", "ceil"} {
+		if mode, err := ParseRoundingMode(name); err != nil || string(mode) != name {
+			t.Errorf("ParseRoundingMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+	if _, err := ParseRoundingMode("truncate"); err == nil {
+		t.Errorf("ParseRoundingMode accepted an unknown mode")
+	}
+}
+
+func TestProcessTAPDataHeaderOnly(t *testing.T) {
+	tapData := testutil.TAP(1, nil)
+	pcm, indexData, err := ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no data blocks") {
+		t.Errorf("got error %v, want a 'no data blocks' error", err)
+	}
+	if pcm != nil || indexData != nil {
+		t.Errorf("got %d samples and %d index entries for a header-only tap", len(pcm), len(indexData))
+	}
+}
+
+// _halfWavePayload converts a v1 payload into the equivalent v2 payload: every pulse becomes a
+// high and a low half-wave of half its duration, pauses keep their single overflow.
+func _halfWavePayload(payload []byte) []byte {
+	out := make([]byte, 0, 2*len(payload))
+	for i := 0; i < len(payload); i++ {
+		if payload[i] == 0 {
+			out = append(out, payload[i:i+4]...)
+			i += 3
+			continue
+		}
+		out = append(out, payload[i]/2, payload[i]-payload[i]/2)
+	}
+	return out
+}
+
+// _risingEdges returns the sample positions where 8-bit pcm rises above the 128 dc level.
+func _risingEdges(pcm []byte) []int {
+	var edges []int
+	for i, sample := range pcm {
+		if sample > 128 && (i == 0 || pcm[i-1] <= 128) {
+			edges = append(edges, i)
+		}
+	}
+	return edges
+}
+
+func TestProcessTAPDataHalfWaveTiming(t *testing.T) {
+	testutil.Quiet(t)
+	payload := testutil.MultiBlockPayload(2, 500)
+	v1 := testutil.TAP(1, payload)
+	v2 := testutil.TAP(2, _halfWavePayload(payload))
+	for _, rounding := range []RoundingMode{RoundFloor, RoundNearest, RoundCeil} {
+		t.Run(string(rounding), func(t *testing.T) {
+			opts := ProcessOptions{Rounding: rounding}
+			pcm1, index1, err := ProcessTAPData(v1, 1, constants.ClockPAL, constants.SampleRate, nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcm2, index2, err := ProcessTAPData(v2, 2, constants.ClockPAL, constants.SampleRate, nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// pulses start at the same samples; the falling edge within a pulse may differ by a
+			// sample, as v2 rounds the high half-wave by its own duration
+			if len(pcm2) != len(pcm1) {
+				t.Errorf("v2 audio has %d samples, want %d", len(pcm2), len(pcm1))
+			}
+			if edges1, edges2 := _risingEdges(pcm1), _risingEdges(pcm2); !slices.Equal(edges2, edges1) {
+				t.Errorf("v2 has %d pulse starts, v1 %d (or they differ in position)", len(edges2), len(edges1))
+			}
+			if len(index2) != len(index1) {
+				t.Fatalf("v2 has %d blocks, want %d", len(index2), len(index1))
+			}
+			for i := range index1 {
+				if index2[i].Type != index1[i].Type || index2[i].StartSample != index1[i].StartSample || index2[i].EndSample != index1[i].EndSample {
+					t.Errorf("block %d: v2 %s %d-%d, want %s %d-%d", i, index2[i].Type, index2[i].StartSample, index2[i].EndSample,
+						index1[i].Type, index1[i].StartSample, index1[i].EndSample)
+				}
+			}
+		})
+	}
+}
+
+func TestIsLeadToneGlitches(t *testing.T) {
+	leadValue, tolerance := byte(constants.LeadPulseC64), byte(constants.LeadPulseToleranceC64)
+	glitched := func(positions ...int) []byte {
+		lead := testutil.Lead(constants.MinLeadToneLength + 1000)
+		for _, pos := range positions {
+			lead[pos] = 0x80
+		}
+		return lead
+	}
+	// every 5th byte a data pulse: 80% of the window matches
+	sparse := testutil.Lead(constants.MinLeadToneLength)
+	for i := 4; i < len(sparse); i += 5 {
+		sparse[i] = 0x56
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		glitches int
+		want     bool
+	}{
+		{"clean lead", glitched(), 0, true},
+		{"single glitch", glitched(100), 0, true},
+		{"glitch at the start of the window", glitched(1), 0, true},
+		{"scattered glitches", glitched(10, 5000, 12000, 24000), 0, true},
+		{"glitches within the limit", glitched(10, 5000, 12000), 3, true},
+		{"glitches beyond the limit", glitched(10, 5000, 12000, 24000), 3, false},
+		{"data pulses", testutil.Data(constants.MinLeadToneLength), 0, false},
+		{"lead-like run with data pulses", sparse, 0, false},
+		// the window spans the pause, the scan stops there: the pilot after it does not count
+		{"pause within the window", slices.Concat(testutil.Lead(20000), testutil.Pause(20000), testutil.Lead(10000)), 0, false},
+		{"pause at the end of a consistent window", slices.Concat(testutil.Lead(23000), testutil.Pause(20000), testutil.Lead(10000)), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tapData := testutil.TAP(1, tt.data)
+			isLead, reason := _explainLeadTone(tapData, constants.TapHeaderSize, leadValue, tolerance, tt.glitches)
+			if isLead != tt.want {
+				t.Errorf("lead %v (%s), want %v", isLead, reason, tt.want)
+			}
+			if !isLead && reason == "" {
+				t.Error("no reason given for a rejected lead")
+			}
+		})
+	}
+}
+
+func TestProcessTAPDataPolarityMirrors(t *testing.T) {
+	testutil.Quiet(t)
+	tapData := testutil.TAP(1, testutil.MultiBlockPayload(2, 500))
+	normal, index, err := ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{Polarity: PolarityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inverted, invertedIndex, err := ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{Polarity: PolarityInverted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inverted) != len(normal) || len(invertedIndex) != len(index) {
+		t.Fatalf("inverted output has %d samples in %d blocks, want %d in %d", len(inverted), len(invertedIndex), len(normal), len(index))
+	}
+
+	// every sample of lead, data and pause blocks mirrors around the 128 dc level
+	for _, entry := range index {
+		for i := entry.StartSample; i <= entry.EndSample; i++ {
+			if int(inverted[i]) != 256-int(normal[i]) {
+				t.Fatalf("%s block sample %d: inverted %d, normal %d; want a mirror around 128", entry.Type, i, inverted[i], normal[i])
+			}
+		}
+	}
+
+	// blocks end on the opposite level of the one the next block starts on, in both polarities,
+	// so the waveform stays phase-continuous across block boundaries
+	for _, entry := range index[1:] {
+		b := entry.StartSample
+		for _, pcm := range [][]byte{normal, inverted} {
+			if (pcm[b-1] > 128) == (pcm[b] > 128) {
+				t.Errorf("no edge at the start of the %s block at sample %d: %d -> %d", entry.Type, b, pcm[b-1], pcm[b])
+			}
+		}
+		if normal[b] < 128 || inverted[b] > 128 {
+			t.Errorf("%s block at sample %d starts at %d (normal) and %d (inverted); want high and low", entry.Type, b, normal[b], inverted[b])
+		}
+	}
+}
+
+func TestProcessTAPDataAmplitude(t *testing.T) {
+	testutil.Quiet(t)
+	tapData := testutil.TAP(1, testutil.MultiBlockPayload(1, 500))
+	// largest deviation from the 128 dc level per block type
+	peaks := func(amp byte, waveform Waveform) map[string]int {
+		pcm, index, err := ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, ProcessOptions{Amplitude: amp, Waveform: waveform})
+		if err != nil {
+			t.Fatal(err)
+		}
+		peak := make(map[string]int)
+		for _, entry := range index {
+			for _, sample := range pcm[entry.StartSample : entry.EndSample+1] {
+				peak[entry.Type] = max(peak[entry.Type], abs(int(sample)-128))
+			}
+		}
+		return peak
+	}
+	for _, waveform := range []Waveform{WaveSquare, WaveSine} {
+		// sine samples are taken between the peaks, so pulses may stay just below the amplitude
+		slack := 0
+		if waveform == WaveSine {
+			slack = 1
+		}
+		for _, amp := range []int{127, 40} {
+			peak := peaks(byte(amp), waveform)
+			for _, blockType := range []string{"lead", "data", "pause"} {
+				if peak[blockType] > amp || peak[blockType] < amp-slack {
+					t.Errorf("%s %s: amplitude %d peaks at %d", waveform, blockType, amp, peak[blockType])
+				}
+			}
+		}
+	}
+}
+
+func TestProcessTAPDataAsymmetricHalfWaves(t *testing.T) {
+	// clearly unequal high and low half-waves, in both orders
+	pairs := [][2]byte{{0x10, 0x40}, {0x40, 0x10}, {0x0b, 0x21}}
+	var payload []byte
+	for i := 0; i < 300; i++ {
+		payload = append(payload, pairs[i%len(pairs)][0], pairs[i%len(pairs)][1])
+	}
+	for _, rounding := range []RoundingMode{RoundFloor, RoundNearest, RoundCeil} {
+		t.Run(string(rounding), func(t *testing.T) {
+			testutil.Quiet(t)
+			opts := ProcessOptions{Rounding: rounding}
+			pcm, _, err := ProcessTAPData(testutil.TAP(2, payload), 2, constants.ClockPAL, constants.SampleRate, nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toSamples := func(units int) int {
+				return cyclesToSamples(uint32(units)*constants.TapCyclesPerUnit, constants.ClockPAL, constants.SampleRate, rounding)
+			}
+
+			// split the square wave into its high and low runs
+			var runs []int
+			for i := 0; i < len(pcm); {
+				start := i
+				for i < len(pcm) && (pcm[i] > 128) == (pcm[start] > 128) {
+					i++
+				}
+				runs = append(runs, i-start)
+			}
+			if len(runs) != len(payload) {
+				t.Fatalf("%d high and low runs, want %d half-waves", len(runs), len(payload))
+			}
+			for i := 0; i < len(payload); i += 2 {
+				high, low := int(payload[i]), int(payload[i+1])
+				// the high half takes its own duration, the low half the rest of the cycle
+				wantHigh := toSamples(high)
+				wantLow := toSamples(high+low) - wantHigh
+				if runs[i] != wantHigh || runs[i+1] != wantLow {
+					t.Fatalf("half-waves 0x%02x/0x%02x at %d: %d/%d samples, want %d/%d", high, low, i, runs[i], runs[i+1], wantHigh, wantLow)
+				}
+				if abs(wantLow-toSamples(low)) > 1 {
+					t.Fatalf("low half-wave 0x%02x: %d samples, more than one off its own %d", low, wantLow, toSamples(low))
+				}
+			}
+		})
+	}
+}
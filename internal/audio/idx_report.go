@@ -0,0 +1,50 @@
+// internal/audio/idx_report.go
+
+package audio
+
+import (
+	"go_chirp_the_tap/internal/idx"
+	"sort"
+)
+
+// IDXReport describes how well an .idx file aligns with the detected blocks.
+type IDXReport struct {
+	Matched        int            // number of blocks that receive a tag
+	UnmatchedIDX   []idx.IDXEntry // idx entries without a block within tolerance
+	ShadowedIDX    []idx.IDXEntry // idx entries that match a block already tagged by a later entry
+	UntaggedBlocks []IndexEntry   // lead and data blocks no idx entry matches
+}
+
+// ValidateIDX reports how idxEntries would be merged into index using the exact matching rule
+// of the merge in ProcessTAPData (with tolerance in place of constants.MaxOffset), without
+// changing either slice. positions are compared as given, so apply any idx offset beforehand.
+func ValidateIDX(index []IndexEntry, idxEntries []idx.IDXEntry, tolerance int) IDXReport {
+	// work on copies, the matching sorts both slices
+	blocks := make([]IndexEntry, len(index))
+	copy(blocks, index)
+	entries := make([]idx.IDXEntry, len(idxEntries))
+	copy(entries, idxEntries)
+
+	report := IDXReport{}
+	tagged := make(map[int]int) // block index -> idx entry index that tags it (last one wins)
+	for j, blockIdx := range _matchIDXEntries(blocks, entries, tolerance) {
+		if blockIdx == -1 {
+			report.UnmatchedIDX = append(report.UnmatchedIDX, entries[j])
+			continue
+		}
+		if previous, ok := tagged[blockIdx]; ok {
+			report.ShadowedIDX = append(report.ShadowedIDX, entries[previous])
+		}
+		tagged[blockIdx] = j
+	}
+	report.Matched = len(tagged)
+
+	for i, block := range blocks {
+		if _, ok := tagged[i]; !ok && (block.Type == "data" || block.Type == "lead") {
+			report.UntaggedBlocks = append(report.UntaggedBlocks, block)
+		}
+	}
+	sort.SliceStable(report.ShadowedIDX, func(i, j int) bool { return report.ShadowedIDX[i].Position < report.ShadowedIDX[j].Position })
+
+	return report
+}
@@ -0,0 +1,88 @@
+// internal/audio/pulses.go
+package audio
+
+import (
+	"fmt"
+	"go_chirp_the_tap/internal/constants"
+)
+
+// PulseEntry holds per-pulse analysis data for a single pulse (or pause) of a .tap file.
+// for a v2 (half-wave) tap, every entry is a half-wave.
+type PulseEntry struct {
+	Position    int    // position of the pulse byte in the original tap file (includes header offset)
+	Value       byte   // raw pulse byte value (0 for a pause)
+	Cycles      uint32 // duration in cpu cycles
+	StartSample int    // starting sample index within the generated pcm data
+	EndSample   int    // ending sample index within the generated pcm data (inclusive)
+	Type        string // "pulse", "long" (overflow pulse within a block) or "pause"
+}
+
+// AnalysePulses records every pulse and pause whose tap file position lies within
+// [startPos, endPos], with its value, cycle count and sample range in the generated audio.
+// the sample accounting mirrors ProcessTAPData without generating any pcm data. as this is
+// very verbose, at most constants.MaxAnalysedPulses entries are returned; the range is cut
+// short (with a warning) beyond that.
+func AnalysePulses(tapData []byte, version byte, clock, sampleRate float64, startPos, endPos int, opts ProcessOptions) ([]PulseEntry, error) {
+	if len(tapData) < constants.TapHeaderSize {
+		return nil, fmt.Errorf("tap data too short: %d bytes, expected at least %d", len(tapData), constants.TapHeaderSize)
+	}
+	if startPos > endPos {
+		return nil, fmt.Errorf("invalid pulse range: start %d is after end %d", startPos, endPos)
+	}
+
+	pulses := make([]PulseEntry, 0, 1024)
+	currentSample := 0
+	inBlock := false           // true after a pulse, where a short overflow may be a long pulse
+	var sampler *_pulseSampler // pulse sample counts of the current block, see _pulseSampler
+	i := constants.TapHeaderSize
+
+	// walk the pulses up to the end of the requested range, counting samples on the way
+	for i < len(tapData) && i <= endPos {
+		var bytesRead int
+		var cycles uint32
+		pulseType := "pulse"
+
+		if tapData[i] == 0 {
+			var err error
+			bytesRead, cycles, err = _pauseCycles(tapData, i, version)
+			if err != nil {
+				return nil, fmt.Errorf("error analysing pause at file offset %d: %w", i, err)
+			}
+			pulseType = "pause"
+			if (inBlock && opts.isLongPulse(version, cycles)) || _startsWithLongPulse(tapData, i, version, opts) {
+				pulseType = "long" // long pulse within or opening a block, see ProcessTAPData
+			}
+		} else {
+			bytesRead = 1
+			cycles = uint32(tapData[i]) * opts.cyclesPerUnit()
+		}
+		samples := cyclesToSamples(cycles, clock, sampleRate, opts.Rounding)
+		if pulseType != "pause" {
+			if !inBlock {
+				sampler = _newPulseSampler(version, clock, sampleRate, opts.Rounding)
+			}
+			samples, _ = sampler.samples(cycles)
+		}
+		inBlock = pulseType != "pause"
+
+		if i >= startPos {
+			if len(pulses) == constants.MaxAnalysedPulses {
+				fmt.Printf("warning: pulse analysis limited to %d pulses, stopping at file offset %d.\n", constants.MaxAnalysedPulses, i)
+				break
+			}
+			pulses = append(pulses, PulseEntry{
+				Position:    i,
+				Value:       tapData[i],
+				Cycles:      cycles,
+				StartSample: currentSample,
+				EndSample:   currentSample + samples - 1,
+				Type:        pulseType,
+			})
+		}
+
+		currentSample += samples
+		i += bytesRead
+	}
+
+	return pulses, nil
+}
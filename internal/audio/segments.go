@@ -0,0 +1,57 @@
+// internal/audio/segments.go
+
+package audio
+
+import "sort"
+
+// LengthStats summarises a distribution of segment lengths in samples.
+type LengthStats struct {
+	Count  int
+	Min    int
+	Median int
+	Max    int
+}
+
+// SegmentStats holds the length distributions of the blocks (lead and data) and pauses
+// of a processed tap, plus a suggested minimum block length for cleanup.
+type SegmentStats struct {
+	Blocks              LengthStats
+	Pauses              LengthStats
+	SuggestedMinSamples int // blocks shorter than this look like noise; 0 if none do
+}
+
+// AnalyseSegments reports the length distribution of blocks and pauses in indexData and
+// suggests a minimum block length for DropShortBlocks. the suggestion is conservative: it is
+// 1% of the median block length, and only made if some blocks are actually shorter than that
+// (real blocks on a tape are rarely 100 times shorter than the typical one).
+func AnalyseSegments(indexData []IndexEntry) SegmentStats {
+	var blockLengths, pauseLengths []int
+	for _, entry := range indexData {
+		length := entry.DurationSamples()
+		if entry.Type == "pause" {
+			pauseLengths = append(pauseLengths, length)
+		} else {
+			blockLengths = append(blockLengths, length)
+		}
+	}
+
+	stats := SegmentStats{Blocks: _lengthStats(blockLengths), Pauses: _lengthStats(pauseLengths)}
+	if threshold := stats.Blocks.Median / 100; threshold > 0 && stats.Blocks.Min < threshold {
+		stats.SuggestedMinSamples = threshold
+	}
+	return stats
+}
+
+// _lengthStats computes count, min, median and max of lengths (sorted in place).
+func _lengthStats(lengths []int) LengthStats {
+	if len(lengths) == 0 {
+		return LengthStats{}
+	}
+	sort.Ints(lengths)
+	return LengthStats{
+		Count:  len(lengths),
+		Min:    lengths[0],
+		Median: lengths[len(lengths)/2],
+		Max:    lengths[len(lengths)-1],
+	}
+}
@@ -0,0 +1,63 @@
+// internal/audio/sync.go
+
+package audio
+
+import "fmt"
+
+// SyncMode selects the reference signal generated by SyncTrack.
+type SyncMode string
+
+const (
+	SyncBlocks SyncMode = "block" // one marker pulse at the start of every lead and data block
+	SyncEdges  SyncMode = "edges" // one short marker pulse at every rising pulse edge (bit clock)
+)
+
+// ParseSyncMode validates a sync track mode name ("block" or "edges").
+func ParseSyncMode(name string) (SyncMode, error) {
+	switch mode := SyncMode(name); mode {
+	case SyncBlocks, SyncEdges:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported sync track mode: %s (use 'block' or 'edges')", name)
+}
+
+// SyncTrack generates a timing reference channel aligned to pcmSamples, for tape writers that
+// take a second channel as sync (use InterleaveStereo to combine both). the track rests at
+// the 128 dc level and holds the high level (255) for each marker: 1 ms at the start sample of
+// every lead/data block in SyncBlocks mode, 0.1 ms (at least one sample) at every rising edge
+// of the data (crossing above 128) in SyncEdges mode.
+func SyncTrack(pcmSamples []byte, indexData []IndexEntry, sampleRate int, mode SyncMode) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	track := make([]byte, len(pcmSamples))
+	for i := range track {
+		track[i] = 128
+	}
+	// mark holds the high level for length samples from start, capped to the track
+	mark := func(start, length int) {
+		for i := start; i < min(start+length, len(track)); i++ {
+			track[i] = 255
+		}
+	}
+
+	switch mode {
+	case SyncBlocks:
+		markerLength := max(1, sampleRate/1000)
+		for _, entry := range indexData {
+			if (entry.Type == "lead" || entry.Type == "data") && entry.StartSample >= 0 {
+				mark(entry.StartSample, markerLength)
+			}
+		}
+	case SyncEdges:
+		markerLength := max(1, sampleRate/10000)
+		for i := 1; i < len(pcmSamples); i++ {
+			if pcmSamples[i-1] <= 128 && pcmSamples[i] > 128 {
+				mark(i, markerLength)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sync track mode: %s", mode)
+	}
+	return track, nil
+}
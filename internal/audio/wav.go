@@ -3,27 +3,71 @@ package audio
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
-	"os"
+	"math"
 )
 
 const (
 	// standard wav header constants
-	riffChunkID   = "RIFF"
-	waveFormatID  = "WAVE"
-	fmtChunkID    = "fmt "
-	dataChunkID   = "data"
-	pcmFormatTag  = 1  // pcm audio format
-	numChannels   = 1  // mono audio
-	bitsPerSample = 8  // 8-bit audio
-	blockAlign    = 1  // numChannels * bitsPerSample/8
-	fmtChunkSize  = 16 // size of the fmt chunk
+	riffChunkID  = "RIFF"
+	waveFormatID = "WAVE"
+	fmtChunkID   = "fmt "
+	dataChunkID  = "data"
+	pcmFormatTag = 1  // pcm audio format
+	fmtChunkSize = 16 // size of the fmt chunk
+
+	// broadcast wave (ebu tech 3285) bext chunk, version 1 without coding history
+	bextChunkID   = "bext"
+	bextChunkSize = 602
 )
 
-// WriteWAVHeader writes a wav header to the given writer
-func WriteWAVHeader(w io.Writer, sampleRate int, dataSize int) error {
+// BextInfo holds the fields of a broadcast wave (bwf) bext chunk. all fields are ascii and
+// truncated to their fixed sizes; the time reference and umid are left zero.
+type BextInfo struct {
+	Description         string // up to 256 characters, e.g. source file and program names
+	Originator          string // up to 32 characters, the creating application
+	OriginatorReference string // up to 32 characters
+	OriginationDate     string // "yyyy-mm-dd"
+	OriginationTime     string // "hh:mm:ss"
+}
+
+// bytes returns the chunk data of the bext chunk (without the chunk header).
+func (b BextInfo) bytes() []byte {
+	data := make([]byte, bextChunkSize)
+	copy(data[0:256], b.Description)
+	copy(data[256:288], b.Originator)
+	copy(data[288:320], b.OriginatorReference)
+	copy(data[320:330], b.OriginationDate)
+	copy(data[330:338], b.OriginationTime)
+	// 338-345: time reference (zero), 346-347: version, 348-411: umid, 412-601: reserved
+	binary.LittleEndian.PutUint16(data[346:348], 1)
+	return data
+}
+
+// WriteWAVHeader writes a wav header for dataSize bytes of interleaved pcm data to the given
+// writer. bitsPerSample must be 8 (unsigned) or 16 (signed little-endian), channels 1 or 2.
+// if bext is not nil, a broadcast wave bext chunk is added between the fmt and data chunks;
+// players without bwf support skip it.
+func WriteWAVHeader(w io.Writer, sampleRate int, bitsPerSample int, channels int, dataSize int, bext *BextInfo) error {
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return fmt.Errorf("unsupported bits per sample: %d (must be 8 or 16)", bitsPerSample)
+	}
+	if channels != 1 && channels != 2 {
+		return fmt.Errorf("unsupported number of channels: %d (must be 1 or 2)", channels)
+	}
+	blockAlign := channels * bitsPerSample / 8
+
 	// Calculate sizes
 	fileSize := 36 + dataSize // total file size minus 8 bytes for the riff header
+	if bext != nil {
+		fileSize += 8 + bextChunkSize
+	}
+
+	// riff sizes are 32-bit; larger values would silently wrap and corrupt the header
+	if dataSize < 0 || int64(fileSize) > math.MaxUint32 {
+		return fmt.Errorf("wav data size %d bytes exceeds the 4GB riff limit (use pcm output or a cpk package instead)", dataSize)
+	}
 
 	// write riff header
 	if err := writeString(w, riffChunkID); err != nil {
@@ -46,13 +90,13 @@ func WriteWAVHeader(w io.Writer, sampleRate int, dataSize int) error {
 	if err := binary.Write(w, binary.LittleEndian, uint16(pcmFormatTag)); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint16(numChannels)); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, uint16(channels)); err != nil {
 		return err
 	}
 	if err := binary.Write(w, binary.LittleEndian, uint32(sampleRate)); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, uint32(sampleRate*numChannels*bitsPerSample/8)); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, uint32(sampleRate*blockAlign)); err != nil {
 		return err
 	}
 	if err := binary.Write(w, binary.LittleEndian, uint16(blockAlign)); err != nil {
@@ -62,6 +106,19 @@ func WriteWAVHeader(w io.Writer, sampleRate int, dataSize int) error {
 		return err
 	}
 
+	// write the optional bext chunk
+	if bext != nil {
+		if err := writeString(w, bextChunkID); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(bextChunkSize)); err != nil {
+			return err
+		}
+		if _, err := w.Write(bext.bytes()); err != nil {
+			return err
+		}
+	}
+
 	// write data chunk header
 	if err := writeString(w, dataChunkID); err != nil {
 		return err
@@ -73,20 +130,122 @@ func WriteWAVHeader(w io.Writer, sampleRate int, dataSize int) error {
 	return nil
 }
 
-// WriteWAVFile creates a wav file from pcm data
-func WriteWAVFile(filename string, pcmData []byte, sampleRate int) error {
-	file, err := os.Create(filename)
-	if err != nil {
+// WriteWAV writes a complete wav file (header and already interleaved pcm data) to w, with a
+// bext chunk if bext is not nil (see WriteWAVHeader).
+func WriteWAV(w io.Writer, pcmData []byte, sampleRate int, bitsPerSample int, channels int, bext *BextInfo) error {
+	if err := WriteWAVHeader(w, sampleRate, bitsPerSample, channels, len(pcmData), bext); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	if err := WriteWAVHeader(file, sampleRate, len(pcmData)); err != nil {
-		return err
+	_, err := w.Write(pcmData)
+	return err
+}
+
+// InterleaveStereo interleaves two unsigned 8-bit channels into stereo frames (left, right).
+// the shorter channel is padded with the 128 dc level.
+func InterleaveStereo(left, right []byte) []byte {
+	n := max(len(left), len(right))
+	out := make([]byte, 0, n*2)
+	for i := 0; i < n; i++ {
+		l, r := byte(128), byte(128)
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		out = append(out, l, r)
 	}
+	return out
+}
 
-	_, err = file.Write(pcmData)
-	return err
+// DualChannel duplicates unsigned 8-bit mono samples into stereo frames (left, right). with
+// invertRight the right channel is phase-inverted around the 128 dc level, e.g. to test head
+// azimuth correction on real decks.
+func DualChannel(samples []byte, invertRight bool) []byte {
+	if !invertRight {
+		return InterleaveStereo(samples, samples)
+	}
+	right := append([]byte(nil), samples...)
+	_invertSamples(right)
+	return InterleaveStereo(samples, right)
+}
+
+// ParseWAVHeader reads a wav header from r and returns its format and the size of the data
+// chunk in bytes. it validates the RIFF/WAVE structure, requires an uncompressed pcm fmt chunk
+// before the data chunk and skips any other chunks (e.g. cue or list) on the way. on success r
+// is positioned at the first sample of the data chunk.
+func ParseWAVHeader(r io.Reader) (sampleRate, bitsPerSample, channels, dataSize int, err error) {
+	var riffHeader [12]byte
+	if _, err := io.ReadFull(r, riffHeader[:]); err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("error reading riff header: %w", err)
+	}
+	if string(riffHeader[0:4]) != riffChunkID || string(riffHeader[8:12]) != waveFormatID {
+		return 0, 0, 0, 0, fmt.Errorf("not a wav file: missing %s/%s signature", riffChunkID, waveFormatID)
+	}
+
+	fmtFound := false
+	for {
+		// each chunk starts with a 4 byte id and a 4 byte little-endian size
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("error reading chunk header (no %s chunk found): %w", dataChunkID, err)
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch chunkID {
+		case fmtChunkID:
+			if chunkSize < fmtChunkSize {
+				return 0, 0, 0, 0, fmt.Errorf("%s chunk too short: %d bytes", fmtChunkID, chunkSize)
+			}
+			// read the pcm fields only; the size comes from the file, so extensions (and the
+			// pad byte of odd sizes) are skipped instead of being allocated
+			fmtData := make([]byte, fmtChunkSize)
+			if _, err := io.ReadFull(r, fmtData); err != nil {
+				return 0, 0, 0, 0, fmt.Errorf("error reading %s chunk: %w", fmtChunkID, err)
+			}
+			if rest := chunkSize - fmtChunkSize + chunkSize%2; rest > 0 {
+				if _, err := io.CopyN(io.Discard, r, rest); err != nil {
+					return 0, 0, 0, 0, fmt.Errorf("error reading %s chunk: %w", fmtChunkID, err)
+				}
+			}
+			if formatTag := binary.LittleEndian.Uint16(fmtData[0:2]); formatTag != pcmFormatTag {
+				return 0, 0, 0, 0, fmt.Errorf("unsupported wav format tag %d (only pcm supported)", formatTag)
+			}
+			channels = int(binary.LittleEndian.Uint16(fmtData[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(fmtData[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(fmtData[14:16]))
+			if channels <= 0 || sampleRate <= 0 || bitsPerSample <= 0 {
+				return 0, 0, 0, 0, fmt.Errorf("invalid wav format: %d channels, %d hz, %d bits", channels, sampleRate, bitsPerSample)
+			}
+			fmtFound = true
+
+		case dataChunkID:
+			if !fmtFound {
+				return 0, 0, 0, 0, fmt.Errorf("%s chunk found before %s chunk", dataChunkID, fmtChunkID)
+			}
+			return sampleRate, bitsPerSample, channels, int(chunkSize), nil
+
+		default:
+			// skip unknown chunk including its pad byte
+			if _, err := io.CopyN(io.Discard, r, chunkSize+chunkSize%2); err != nil {
+				return 0, 0, 0, 0, fmt.Errorf("error skipping '%s' chunk: %w", chunkID, err)
+			}
+		}
+	}
+}
+
+// ToSigned16 converts unsigned 8-bit pcm samples (centred on 128) into signed 16-bit
+// little-endian samples centred on zero. the conversion recentres before scaling, so the
+// high/low levels of a square wave map symmetrically to +amp/-amp and a balanced wave stays
+// free of dc offset (255 -> +32512, 1 -> -32512), which ac-coupled tape inputs prefer.
+func ToSigned16(samples []byte) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16((int(sample)-128)*256)))
+	}
+	return out
 }
 
 func writeString(w io.Writer, s string) error {
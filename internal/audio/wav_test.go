@@ -0,0 +1,188 @@
+// internal/audio/wav_test.go
+
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestWAVHeaderRejectsSizesBeyondUint32(t *testing.T) {
+	// the riff size field holds 36 header bytes plus the data (and 610 more with a bext chunk)
+	maxData := math.MaxUint32 - 36
+	tests := []struct {
+		name     string
+		dataSize int
+		bext     *BextInfo
+		wantErr  bool
+	}{
+		{"largest data size", maxData, nil, false},
+		{"one byte over", maxData + 1, nil, true},
+		{"largest with bext", maxData - 8 - bextChunkSize, &BextInfo{}, false},
+		{"one byte over with bext", maxData - 8 - bextChunkSize + 1, &BextInfo{}, true},
+		{"negative", -1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WriteWAVHeader(io.Discard, 44100, 16, 1, tt.dataSize, tt.bext)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("data size %d: error %v, want error %v", tt.dataSize, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToSigned16IsDCFree(t *testing.T) {
+	// a balanced square wave of 8-bit high/low levels (255/1) averages to zero in 16-bit
+	square := generateWave(1000, 127, 0, WaveSquare)
+	out := ToSigned16(square)
+	if len(out) != 2*len(square) {
+		t.Fatalf("got %d bytes for %d samples", len(out), len(square))
+	}
+	sum := 0
+	for i := 0; i < len(out); i += 2 {
+		sum += int(int16(binary.LittleEndian.Uint16(out[i:])))
+	}
+	if mean := float64(sum) / float64(len(square)); math.Abs(mean) > 1 {
+		t.Errorf("mean sample %.2f, want ~0", mean)
+	}
+
+	// the levels map symmetrically around zero
+	for sample, want := range map[byte]int16{255: 32512, 1: -32512, 128: 0} {
+		if got := int16(binary.LittleEndian.Uint16(ToSigned16([]byte{sample}))); got != want {
+			t.Errorf("sample %d: got %d, want %d", sample, got, want)
+		}
+	}
+}
+
+func TestParseWAVHeader(t *testing.T) {
+	for _, bits := range []int{8, 16} {
+		for _, channels := range []int{1, 2} {
+			for _, bext := range []*BextInfo{nil, {Description: "test"}} {
+				buf := new(bytes.Buffer)
+				if err := WriteWAVHeader(buf, 48000, bits, channels, 1234, bext); err != nil {
+					t.Fatal(err)
+				}
+				buf.WriteString("rest")
+				rate, gotBits, gotChannels, dataSize, err := ParseWAVHeader(buf)
+				if err != nil || rate != 48000 || gotBits != bits || gotChannels != channels || dataSize != 1234 {
+					t.Errorf("%d bits, %d channels, bext %v: got %d hz, %d bits, %d channels, %d bytes, error %v",
+						bits, channels, bext != nil, rate, gotBits, gotChannels, dataSize, err)
+				}
+				if buf.String() != "rest" {
+					t.Errorf("reader not positioned at the data: %q left", buf.String())
+				}
+			}
+		}
+	}
+}
+
+func TestParseWAVHeaderExtraChunks(t *testing.T) {
+	header := new(bytes.Buffer)
+	if err := WriteWAVHeader(header, 44100, 8, 1, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	// insert an odd-sized cue chunk (with its pad byte) between the fmt and data chunks
+	raw := header.Bytes()
+	withCue := append(append([]byte(nil), raw[:36]...), []byte("cue \x03\x00\x00\x00abc\x00")...)
+	withCue = append(withCue, raw[36:]...)
+	_, bits, _, dataSize, err := ParseWAVHeader(bytes.NewReader(withCue))
+	if err != nil || bits != 8 || dataSize != 10 {
+		t.Errorf("got %d bits, %d bytes, error %v", bits, dataSize, err)
+	}
+}
+
+func TestParseWAVHeaderMalformed(t *testing.T) {
+	valid := new(bytes.Buffer)
+	if err := WriteWAVHeader(valid, 44100, 8, 1, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	raw := valid.Bytes()
+	corrupt := func(offset int, data string) []byte {
+		out := append([]byte(nil), raw...)
+		copy(out[offset:], data)
+		return out
+	}
+	tests := map[string][]byte{
+		"empty":            nil,
+		"not riff":         corrupt(0, "RIFX"),
+		"not wave":         corrupt(8, "AVI "),
+		"compressed":       corrupt(20, "\x03\x00"),
+		"data before fmt":  append(append([]byte(nil), raw[:12]...), raw[36:]...),
+		"truncated fmt":    raw[:30],
+		"no data chunk":    raw[:36],
+		"zero sample rate": corrupt(24, "\x00\x00\x00\x00"),
+		"short fmt":        corrupt(16, "\x08\x00\x00\x00"),
+		"oversized fmt":    corrupt(16, "\xf0\xff\xff\xff"),
+	}
+	for name, data := range tests {
+		if _, _, _, _, err := ParseWAVHeader(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestWriteWAV16Bit(t *testing.T) {
+	// 16-bit output widens the generated 8-bit samples (see ToSigned16)
+	pcm := append(generateWave(40, 127, 0, WaveSquare), generateWave(40, 60, 0, WaveSine)...)
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, ToSigned16(pcm), 44100, 16, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	header := buf.Bytes()[:44]
+	fields := []struct {
+		name      string
+		got, want uint32
+	}{
+		{"channels", uint32(binary.LittleEndian.Uint16(header[22:24])), 1},
+		{"sample rate", binary.LittleEndian.Uint32(header[24:28]), 44100},
+		{"byte rate", binary.LittleEndian.Uint32(header[28:32]), 88200},
+		{"block align", uint32(binary.LittleEndian.Uint16(header[32:34])), 2},
+		{"bits per sample", uint32(binary.LittleEndian.Uint16(header[34:36])), 16},
+		{"data size", binary.LittleEndian.Uint32(header[40:44]), uint32(2 * len(pcm))},
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			t.Errorf("%s %d, want %d", f.name, f.got, f.want)
+		}
+	}
+
+	// full-scale square pulses reach +/-32512, the sine stays within its amplitude
+	data := buf.Bytes()[44:]
+	minSample, maxSample := int16(math.MaxInt16), int16(math.MinInt16)
+	for i := range pcm {
+		sample := int16(binary.LittleEndian.Uint16(data[2*i:]))
+		if i >= 40 && (sample > 60*256 || sample < -60*256) {
+			t.Errorf("sine sample %d is %d, beyond +/-%d", i, sample, 60*256)
+		}
+		minSample, maxSample = min(minSample, sample), max(maxSample, sample)
+	}
+	if minSample != -32512 || maxSample != 32512 {
+		t.Errorf("samples range from %d to %d, want -32512 to 32512", minSample, maxSample)
+	}
+}
+
+func TestParseWAVHeaderOversizedFmt(t *testing.T) {
+	// a 44-byte header claiming a ~4 GiB fmt chunk must fail without allocating its size
+	header := new(bytes.Buffer)
+	if err := WriteWAVHeader(header, 44100, 8, 1, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	raw := header.Bytes()
+	binary.LittleEndian.PutUint32(raw[16:20], math.MaxUint32)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, _, _, _, err := ParseWAVHeader(bytes.NewReader(raw))
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("no error for an fmt chunk beyond the end of the file")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("parsing allocated %d bytes", allocated)
+	}
+}
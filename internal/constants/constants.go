@@ -15,11 +15,26 @@ const (
 	RequiredConsistency = 0.9   // at least 90% of bytes must be the same value. we allow leniency here due to poor quality .tap files
 	MaxOffset           = 1500  // .idx files are read and joined with the index generated here. sometimes it does not match, hence the need for a lenient approach (allow an offset in tap file bytes)
 
+	// lead tone pulse value for cbm rom loaders (short pulse) and the tolerance accepted around it
+	LeadPulseC64          = 0x30
+	LeadPulseToleranceC64 = 0x08
+
+	// upper limit of pulses recorded by the per-pulse analysis (avoids dumping gigabytes for a whole tape)
+	MaxAnalysedPulses = 1000000
+
 	// .tap file constants
-	TapHeaderSize        = 20 // header size for C64-TAPE-RAW v0/v1
+	TapHeaderSize        = 20 // header size for C64-TAPE-RAW v0/v1/v2
 	TapSignatureC64      = "C64-TAPE-RAW"
-	TapMaxVersionSupport = 1 // only support for tap version 0 and 1
+	TapMaxVersionSupport = 2 // support for tap version 0, 1 and 2
+	TapVersionHalfWave   = 2 // tap version whose pulse bytes (and overflows) are half-waves
+	TapCyclesPerUnit     = 8 // cpu cycles represented by one unit of a tap pulse byte
 
 	// sample rate
 	SampleRate = 44100.0
+
+	// default conversion settings (see package options)
+	DefaultClockType    = "pal"
+	DefaultTargetSystem = "c64"
+	DefaultWaveform     = "square"
+	DefaultAmplitude    = 127
 )
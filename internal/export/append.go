@@ -0,0 +1,245 @@
+// internal/export/append.go
+
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"io"
+	"slices"
+	"sort"
+	"time"
+)
+
+// AppendToCPK appends the blocks of indexData (grouped by opts.GroupPolicy) to the existing
+// .cpk archive at cpkPath and returns the number of blocks added. as gzip+tar cannot be
+// appended to in place, the archive is read into memory and repacked (through opts.Sink, so
+// the original stays intact until the new archive is complete).
+// block numbering continues after the existing blocks and the new block times continue after
+// the end time of the last existing block; blocks.csv, playlist.m3u and (if present or
+// requested) checksums.txt are extended accordingly. the new blocks use the manifest's block
+// lead-in; the manifest's sample rate, polarity, waveform and amplitude must match sampleRate
+// and opts, as the new blocks would not play back like the existing ones otherwise. split
+// volumes are not supported.
+func AppendToCPK(cpkPath string, pcmSamples []byte, indexData []audio.IndexEntry, sampleRate int, opts PackageOptions) (added int, err error) {
+	if sampleRate <= 0 {
+		return 0, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	if opts.SplitSize > 0 {
+		return 0, errors.New("appending to a split cpk package is not supported")
+	}
+	floatSampleRate := float64(sampleRate)
+
+	files, err := _readCPKFiles(cpkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// existing manifest and block table
+	var manifest PackageManifest
+	if err := json.Unmarshal(files["package_manifest.json"], &manifest); err != nil {
+		return 0, fmt.Errorf("invalid or missing package_manifest.json in %s: %w", cpkPath, err)
+	}
+	if manifest.SampleRate != sampleRate {
+		return 0, fmt.Errorf("sample rate %d hz does not match the package's %d hz", sampleRate, manifest.SampleRate)
+	}
+	if err := _checkAppendFormat(manifest, opts); err != nil {
+		return 0, err
+	}
+	if opts.LeadIn != manifest.BlockLeadInSamples {
+		fmt.Printf("warning: using the package's block lead-in of %d samples for appended blocks (requested %d).\n", manifest.BlockLeadInSamples, opts.LeadIn)
+	}
+	if opts.bitsPerSample() != manifest.AudioBitsPerSample || opts.channels() != manifest.AudioChannels {
+		fmt.Printf("warning: using the package's %d-bit, %d channel format for appended blocks (requested %d-bit, %d channel).\n",
+			manifest.AudioBitsPerSample, manifest.AudioChannels, opts.bitsPerSample(), opts.channels())
+	}
+	blockFormat := PackageOptions{
+		LeadIn:        manifest.BlockLeadInSamples,
+		BitsPerSample: manifest.AudioBitsPerSample,
+		Channels:      manifest.AudioChannels,
+		InvertRight:   manifest.RightInverted,
+		Polarity:      audio.Polarity(manifest.Polarity),
+		Amplitude:     byte(manifest.Amplitude),
+	}
+	rows, err := _parseBlocksCSV(files["blocks.csv"])
+	if err != nil {
+		return 0, err
+	}
+	// rows may be sorted (see BlockOrder), so continue after the latest end time
+	firstBlock := len(rows)
+	timeOffset := 0.0
+	for _, row := range rows {
+		timeOffset = max(timeOffset, row.endTime)
+	}
+
+	// new block wavs, rows and playlist entries with continued numbering and times
+	newRows := _blockRows(indexData, floatSampleRate, opts.GroupPolicy, firstBlock, timeOffset)
+	newWAVs := make(map[string][]byte, len(newRows))
+	playlist := bytes.NewBuffer(files["playlist.m3u"])
+	if playlist.Len() == 0 {
+		playlist.WriteString("#EXTM3U\n")
+	}
+	blockNumber := firstBlock
+	for i := 0; i < len(indexData); {
+		groupInfo := _getGroupedBlockInfo(indexData, i, floatSampleRate, opts.GroupPolicy)
+		i += groupInfo.ConsumedEntries
+		if !groupInfo.IsBlock {
+			continue
+		}
+		row := newRows[blockNumber-firstBlock]
+		blockNumber++
+
+		blockData, sliceErr := _sliceBlockPCM(pcmSamples, groupInfo, blockNumber-1)
+		if sliceErr != nil {
+			return 0, fmt.Errorf("cannot append %s: %w", row.file, sliceErr)
+		}
+		if newWAVs[row.file], err = _blockWAV(blockData, sampleRate, blockFormat); err != nil {
+			return 0, fmt.Errorf("error writing wav for %s: %w", row.file, err)
+		}
+		duration := int(row.endTime - row.startTime + 0.5) // whole seconds, rounded
+		fmt.Fprintf(playlist, "#EXTINF:%d,%s\n%s\n", duration, _playlistTitle(groupInfo, blockNumber-1), row.file)
+	}
+
+	allRows := slices.Concat(rows, newRows)
+	if opts.CSVOrder != "" && opts.CSVOrder != OrderPosition {
+		if err := _sortBlockRows(allRows, opts.CSVOrder); err != nil {
+			return 0, err
+		}
+	}
+	csvData, err := _renderBlocksCSV(allRows)
+	if err != nil {
+		return 0, err
+	}
+	manifest.UpdatedTimestamp = time.Now().UTC().Format(time.RFC3339)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling manifest to json: %w", err)
+	}
+
+	// checksums: extend an existing list, or create one for all blocks if requested
+	checksums, hasChecksums := files["checksums.txt"]
+	if hasChecksums || opts.Checksums {
+		buf := bytes.NewBuffer(checksums)
+		if !hasChecksums {
+			for _, row := range rows {
+				fmt.Fprintf(buf, "%x  %s\n", sha256.Sum256(files[row.file]), row.file)
+			}
+		}
+		for _, row := range newRows {
+			fmt.Fprintf(buf, "%x  %s\n", sha256.Sum256(newWAVs[row.file]), row.file)
+		}
+		files["checksums.txt"] = buf.Bytes()
+	}
+	if len(opts.SourceIDX) > 0 {
+		if _, ok := files["source.idx"]; ok {
+			fmt.Println("warning: package already holds a source.idx, keeping it.")
+		} else {
+			files["source.idx"] = opts.SourceIDX
+		}
+	}
+
+	// archive order as written by WritePackage: manifest, block wavs, csv, playlist, the rest
+	var names []string
+	for _, row := range rows {
+		names = append(names, row.file)
+	}
+	_sortBlockFiles(names) // block number order, also for a sorted blocks.csv
+	for _, row := range newRows {
+		names = append(names, row.file)
+		files[row.file] = newWAVs[row.file]
+	}
+	files["package_manifest.json"] = manifestData
+	files["blocks.csv"] = csvData
+	files["playlist.m3u"] = playlist.Bytes()
+	names = append([]string{"package_manifest.json"}, names...)
+	names = append(names, "blocks.csv", "playlist.m3u")
+	var rest []string
+	for name := range files {
+		if !slices.Contains(names, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+
+	err = WriteOutput(opts.Sink, cpkPath, func(w io.Writer) error {
+		return _writeCPKFiles(w, names, files)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error writing cpk package %s: %w", cpkPath, err)
+	}
+	fmt.Printf("appended %d blocks to archive (now %d blocks): %s\n", len(newRows), len(rows)+len(newRows), cpkPath)
+	return len(newRows), nil
+}
+
+// _checkAppendFormat returns an error if the signal format of opts differs from the one
+// recorded in manifest. manifests without a polarity, waveform or amplitude (older packages)
+// read as the defaults.
+func _checkAppendFormat(manifest PackageManifest, opts PackageOptions) error {
+	recorded := PackageOptions{
+		Polarity:  audio.Polarity(manifest.Polarity),
+		Waveform:  audio.Waveform(manifest.Waveform),
+		Amplitude: byte(manifest.Amplitude),
+	}
+	if opts.polarity() != recorded.polarity() {
+		return fmt.Errorf("polarity %s does not match the package's %s", opts.polarity(), recorded.polarity())
+	}
+	if opts.waveform() != recorded.waveform() {
+		return fmt.Errorf("waveform %s does not match the package's %s", opts.waveform(), recorded.waveform())
+	}
+	if opts.amplitude() != recorded.amplitude() {
+		return fmt.Errorf("amplitude %d does not match the package's %d", opts.amplitude(), recorded.amplitude())
+	}
+	return nil
+}
+
+// _sortBlockFiles sorts block .wav names by block number; sorting by name would put
+// block_1000 before block_999.
+func _sortBlockFiles(names []string) {
+	sort.SliceStable(names, func(i, j int) bool { return _blockFileNumber(names[i]) < _blockFileNumber(names[j]) })
+}
+
+// _blockFileNumber returns the block number of a block .wav name ("block_012_data.wav" is 12),
+// or -1 if name is not a block file.
+func _blockFileNumber(name string) int {
+	var number int
+	if _, err := fmt.Sscanf(name, "block_%d_", &number); err != nil {
+		return -1
+	}
+	return number
+}
+
+// _writeCPKFiles writes the named files, in order, as a gzipped tarball to w.
+func _writeCPKFiles(w io.Writer, names []string, files map[string][]byte) error {
+	gzWriter, err := gzip.NewWriterLevel(w, 7) // same compression level as WritePackage
+	if err != nil {
+		return fmt.Errorf("error creating gzip writer: %w", err)
+	}
+	tarWriter := tar.NewWriter(gzWriter)
+	for _, name := range names {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s missing from package", name)
+		}
+		header := &tar.Header{Name: name, Size: int64(len(data)), Mode: 0644, ModTime: time.Now()}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("error writing tar header for %s: %w", name, err)
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			return fmt.Errorf("error writing %s to tar: %w", name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("error closing tar writer: %w", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		return fmt.Errorf("error closing gzip writer: %w", err)
+	}
+	return nil
+}
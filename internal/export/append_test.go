@@ -0,0 +1,58 @@
+// internal/export/append_test.go
+
+package export
+
+import (
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/testutil"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSortBlockFilesByNumber(t *testing.T) {
+	names := []string{"block_1000_data.wav", "block_100_data.wav", "block_999_lead.wav", "block_001_pause.wav", "block_1001_data.wav"}
+	_sortBlockFiles(names)
+	want := []string{"block_001_pause.wav", "block_100_data.wav", "block_999_lead.wav", "block_1000_data.wav", "block_1001_data.wav"}
+	if !slices.Equal(names, want) {
+		t.Errorf("sorted names %v, want %v", names, want)
+	}
+}
+
+func TestAppendToCPKRejectsFormatMismatch(t *testing.T) {
+	testutil.Quiet(t)
+	processOpts := audio.ProcessOptions{Amplitude: 100, Waveform: audio.WaveSine, Polarity: audio.PolarityInverted}
+	pcm, indexData := _processTestTAP(t, 1, 500, processOpts)
+	base := filepath.Join(t.TempDir(), "append")
+	packageOpts := PackageOptions{Amplitude: 100, Waveform: audio.WaveSine, Polarity: audio.PolarityInverted}
+	if _, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", packageOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*PackageOptions)
+		wantErr string
+	}{
+		{"polarity", func(o *PackageOptions) { o.Polarity = "" }, "polarity"},
+		{"waveform", func(o *PackageOptions) { o.Waveform = audio.WaveSquare }, "waveform"},
+		{"amplitude", func(o *PackageOptions) { o.Amplitude = 0 }, "amplitude"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := packageOpts
+			tt.mutate(&opts)
+			_, err := AppendToCPK(base+".cpk", pcm, indexData, constants.SampleRate, opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("AppendToCPK error %v, want a %s mismatch", err, tt.wantErr)
+			}
+		})
+	}
+
+	added, err := AppendToCPK(base+".cpk", pcm, indexData, constants.SampleRate, packageOpts)
+	if err != nil || added == 0 {
+		t.Fatalf("matching append added %d blocks, error %v", added, err)
+	}
+}
@@ -2,9 +2,28 @@
 package export
 
 import (
+	"fmt"
 	"go_chirp_the_tap/internal/audio"
 )
 
+// GroupPolicy selects how index entries are grouped into exportable blocks.
+type GroupPolicy string
+
+const (
+	GroupLoose    GroupPolicy = "loose"     // lead+pause, data+pause or data alone (default)
+	GroupTight    GroupPolicy = "tight"     // a lead or data entry with all following data and pauses up to the next lead
+	GroupPerEntry GroupPolicy = "per-entry" // every lead and data entry on its own, pauses are not exported
+)
+
+// ParseGroupPolicy validates a group policy name ("loose", "tight" or "per-entry").
+func ParseGroupPolicy(name string) (GroupPolicy, error) {
+	switch policy := GroupPolicy(name); policy {
+	case GroupLoose, GroupTight, GroupPerEntry:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported group policy: %s (use 'loose', 'tight' or 'per-entry')", name)
+}
+
 // _groupedBlockInfo holds results from analyzing a sequence of IndexEntry items
 // to identify a single logical block suitable for export (e.g., lead+pause, data+pause).
 type _groupedBlockInfo struct {
@@ -13,20 +32,24 @@ type _groupedBlockInfo struct {
 	StartEntry      *audio.IndexEntry // pointer to the IndexEntry where the exportable block starts
 	EndEntry        *audio.IndexEntry // pointer to the IndexEntry where the exportable block ends (inclusive)
 	BlockEndTime    float64           // calculated end time (in seconds) for the identified block
-	ConsumedEntries int               // how many entries from indexData were consumed (1 or 2)
+	ConsumedEntries int               // how many entries from indexData were consumed (1 or 2 for the loose policy)
 }
 
 // _getGroupedBlockInfo analyzes the indexData starting at currentIndex to find
 // the next logical, exportable block (like lead+pause or data+pause/lead).
 // it determines the block type, its start/end entries, calculated end time,
 // and how many indexData entries make up this logical block.
-func _getGroupedBlockInfo(indexData []audio.IndexEntry, currentIndex int, sampleRate float64) _groupedBlockInfo {
+// policy selects the grouping; "" (or GroupLoose) uses the patterns below.
+func _getGroupedBlockInfo(indexData []audio.IndexEntry, currentIndex int, sampleRate float64, policy GroupPolicy) _groupedBlockInfo {
 	// default result: assume no block found, consumes only the current entry by default
 	info := _groupedBlockInfo{ConsumedEntries: 1, IsBlock: false}
 	if currentIndex >= len(indexData) {
 		// reached end of data, definitely no block possible.
 		return info
 	}
+	if policy == GroupTight || policy == GroupPerEntry {
+		return _getSpanBlockInfo(indexData, currentIndex, sampleRate, policy)
+	}
 
 	current := &indexData[currentIndex] // pointer to the current entry being examined
 	var next *audio.IndexEntry          // pointer for the next entry, if it exists
@@ -36,7 +59,9 @@ func _getGroupedBlockInfo(indexData []audio.IndexEntry, currentIndex int, sample
 	}
 
 	// block grouping logic: check specific patterns of adjacent entry types.
-	// pauses alone are not considered exportable blocks in this logic.
+	// pauses alone are not considered exportable blocks in this logic. a pause that is not
+	// preceded by a lead or data entry (e.g. the blank run-in of a tape starting with 0x00)
+	// is consumed on its own, so the following lead or data entry is grouped normally.
 
 	// case 1: current entry is "lead".
 	// an exportable lead block requires an immediately following "pause".
@@ -95,6 +120,47 @@ func _getGroupedBlockInfo(indexData []audio.IndexEntry, currentIndex int, sample
 	return info
 }
 
+// _getSpanBlockInfo implements the tight and per-entry policies: a block starts at a lead or data
+// entry and, for the tight policy, spans all following data and pause entries up to the next lead.
+// pauses not covered by a block (and any other entry types) are skipped.
+func _getSpanBlockInfo(indexData []audio.IndexEntry, currentIndex int, sampleRate float64, policy GroupPolicy) _groupedBlockInfo {
+	info := _groupedBlockInfo{ConsumedEntries: 1, IsBlock: false}
+	current := &indexData[currentIndex]
+	if current.Type != "lead" && current.Type != "data" {
+		return info
+	}
+
+	end := currentIndex
+	if policy == GroupTight {
+		for end+1 < len(indexData) && (indexData[end+1].Type == "data" || indexData[end+1].Type == "pause") {
+			end++
+		}
+	}
+
+	info.IsBlock = true
+	info.BlockType = current.Type
+	info.StartEntry = current
+	info.EndEntry = &indexData[end]
+	info.BlockEndTime = _calculateEndTime(info.EndEntry, sampleRate)
+	info.ConsumedEntries = end - currentIndex + 1
+	return info
+}
+
+// CountBlocks returns the number of exportable blocks in indexData under policy, i.e. the number
+// of block .wav files a .cpk archive of it would hold (without invalid sample ranges).
+func CountBlocks(indexData []audio.IndexEntry, sampleRate float64, policy GroupPolicy) int {
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy)
+		if groupInfo.IsBlock {
+			blockCount++
+		}
+		i += groupInfo.ConsumedEntries
+	}
+	return blockCount
+}
+
 // _calculateEndTime computes the precise end time of an index entry based on its samples.
 func _calculateEndTime(entry *audio.IndexEntry, sampleRate float64) float64 {
 	// handle invalid samplerate or entry data gracefully
@@ -105,13 +171,6 @@ func _calculateEndTime(entry *audio.IndexEntry, sampleRate float64) float64 {
 		}
 		return 0.0
 	}
-	// handle case where end sample might be less than start sample (shouldn't happen)
-	if entry.EndSample < entry.StartSample {
-		return entry.StartTime // treat as zero duration
-	}
-
-	// calculate duration in samples (+1 because start/end are inclusive indices)
-	durationSamples := float64(entry.EndSample - entry.StartSample + 1)
-	// calculate duration in seconds and add to start time
-	return entry.StartTime + (durationSamples / sampleRate)
+	// an end sample before the start sample (shouldn't happen) counts as zero duration
+	return entry.EndTime(sampleRate)
 }
@@ -0,0 +1,111 @@
+// internal/export/block_analyser_test.go
+
+package export
+
+import (
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"slices"
+	"testing"
+)
+
+// _testEntries returns index entries of the given types, each 100 samples long.
+func _testEntries(types ...string) []audio.IndexEntry {
+	entries := make([]audio.IndexEntry, len(types))
+	for i, entryType := range types {
+		entries[i] = audio.IndexEntry{
+			Type:        entryType,
+			StartSample: i * 100,
+			EndSample:   i*100 + 99,
+			StartTime:   float64(i),
+		}
+	}
+	return entries
+}
+
+// _groupBlocks walks entries like the exporters do and describes every block as
+// "type first-last (end time)", with the indices of its first and last entry.
+func _groupBlocks(entries []audio.IndexEntry, policy GroupPolicy) []string {
+	const sampleRate = 100 // one second per entry
+	var blocks []string
+	for i := 0; i < len(entries); {
+		info := _getGroupedBlockInfo(entries, i, sampleRate, policy)
+		if info.IsBlock {
+			// entry i starts at second i
+			blocks = append(blocks, fmt.Sprintf("%s %g-%g (%g)", info.BlockType, info.StartEntry.StartTime, info.EndEntry.StartTime, info.BlockEndTime))
+		}
+		i += info.ConsumedEntries
+	}
+	return blocks
+}
+
+func TestGetGroupedBlockInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  map[GroupPolicy][]string
+	}{
+		{
+			name:  "pause first",
+			types: []string{"pause", "lead", "pause", "data", "pause"},
+			want: map[GroupPolicy][]string{
+				GroupLoose:    {"lead 1-2 (3)", "data 3-4 (5)"},
+				GroupTight:    {"lead 1-4 (5)"},
+				GroupPerEntry: {"lead 1-1 (2)", "data 3-3 (4)"},
+			},
+		},
+		{
+			name:  "lead and pause",
+			types: []string{"lead", "pause", "lead", "pause"},
+			want: map[GroupPolicy][]string{
+				GroupLoose:    {"lead 0-1 (2)", "lead 2-3 (4)"},
+				GroupTight:    {"lead 0-1 (2)", "lead 2-3 (4)"},
+				GroupPerEntry: {"lead 0-0 (1)", "lead 2-2 (3)"},
+			},
+		},
+		{
+			name:  "data and lead",
+			types: []string{"lead", "pause", "data", "lead", "pause"},
+			want: map[GroupPolicy][]string{
+				GroupLoose:    {"lead 0-1 (2)", "data 2-2 (3)", "lead 3-4 (5)"},
+				GroupTight:    {"lead 0-2 (3)", "lead 3-4 (5)"},
+				GroupPerEntry: {"lead 0-0 (1)", "data 2-2 (3)", "lead 3-3 (4)"},
+			},
+		},
+		{
+			name:  "trailing data",
+			types: []string{"lead", "pause", "data", "pause", "data"},
+			want: map[GroupPolicy][]string{
+				GroupLoose:    {"lead 0-1 (2)", "data 2-3 (4)", "data 4-4 (5)"},
+				GroupTight:    {"lead 0-4 (5)"},
+				GroupPerEntry: {"lead 0-0 (1)", "data 2-2 (3)", "data 4-4 (5)"},
+			},
+		},
+		{
+			name:  "lead without pause",
+			types: []string{"lead", "data"},
+			want: map[GroupPolicy][]string{
+				GroupLoose:    {"data 1-1 (2)"},
+				GroupTight:    {"lead 0-1 (2)"},
+				GroupPerEntry: {"lead 0-0 (1)", "data 1-1 (2)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, policy := range []GroupPolicy{GroupLoose, GroupTight, GroupPerEntry} {
+			t.Run(tt.name+"/"+string(policy), func(t *testing.T) {
+				got := _groupBlocks(_testEntries(tt.types...), policy)
+				if !slices.Equal(got, tt.want[policy]) {
+					t.Errorf("blocks %q, want %q", got, tt.want[policy])
+				}
+			})
+		}
+	}
+}
+
+func TestGetGroupedBlockInfoDefaultPolicy(t *testing.T) {
+	entries := _testEntries("pause", "lead", "pause", "data", "lead", "pause", "data")
+	if got, want := _groupBlocks(entries, ""), _groupBlocks(entries, GroupLoose); !slices.Equal(got, want) {
+		t.Errorf("default policy blocks %q, want the loose %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+// internal/export/block_render.go
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+)
+
+// RenderBlockWAV returns a complete .wav file (header and data) for a single grouped block,
+// identified by its block number as used in the .cpk archive and blocks.csv (block_NNN_*.wav).
+// this allows previewing one block without building the whole package. the sample range is
+// bounds-checked the same way SplitAndPackageBlocks does, and with the same opts (grouping,
+// lead-in, bit depth and channels) the result has the same bytes as the block in the package.
+func RenderBlockWAV(pcmSamples []byte, indexData []audio.IndexEntry, blockNumber int, sampleRate int, opts PackageOptions) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	if blockNumber < 0 {
+		return nil, fmt.Errorf("invalid block number: %d", blockNumber)
+	}
+	floatSampleRate := float64(sampleRate)
+
+	// walk the grouped blocks until the requested block number is reached
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, floatSampleRate, opts.GroupPolicy)
+		if groupInfo.IsBlock {
+			if blockCount == blockNumber {
+				blockData, err := _sliceBlockPCM(pcmSamples, groupInfo, blockCount)
+				if err != nil {
+					return nil, err
+				}
+				return _blockWAV(blockData, sampleRate, opts)
+			}
+			blockCount++
+		}
+		i += groupInfo.ConsumedEntries
+	}
+
+	return nil, fmt.Errorf("block %d not found (%d blocks available)", blockNumber, blockCount)
+}
+
+// _sliceBlockPCM extracts the pcm samples of a grouped block from pcmSamples.
+// the end index is capped (with a warning) if it goes beyond the available pcm data;
+// an error is returned if the sample range is invalid, out of bounds or empty.
+func _sliceBlockPCM(pcmSamples []byte, groupInfo _groupedBlockInfo, blockNumber int) ([]byte, error) {
+	blockStartSample := groupInfo.StartEntry.StartSample
+	// add +1 to EndSample because slice range notation [start:end] is exclusive at the 'end' index
+	blockEndSampleIndex := groupInfo.EndEntry.EndSample + 1
+
+	// basic checks for sample indices
+	if blockStartSample < 0 || blockEndSampleIndex <= blockStartSample {
+		return nil, fmt.Errorf("block %d has invalid sample range: start=%d, end=%d", blockNumber, blockStartSample, groupInfo.EndEntry.EndSample)
+	}
+	// ensure indices are within the bounds of the source pcmSamples slice
+	if blockStartSample >= len(pcmSamples) {
+		return nil, fmt.Errorf("block %d start sample %d out of bounds (pcm len %d)", blockNumber, blockStartSample, len(pcmSamples))
+	}
+	// cap end index if it goes beyond available pcm data (e.g., due to rounding)
+	if blockEndSampleIndex > len(pcmSamples) {
+		fmt.Printf("warning: block %d end sample %d out of bounds (pcm len %d), truncating.\n", blockNumber, groupInfo.EndEntry.EndSample, len(pcmSamples))
+		blockEndSampleIndex = len(pcmSamples)
+	}
+
+	blockData := pcmSamples[blockStartSample:blockEndSampleIndex]
+	if len(blockData) == 0 {
+		return nil, fmt.Errorf("block %d resulted in zero samples after slicing", blockNumber)
+	}
+	return blockData, nil
+}
+
+// _blockWAV builds a complete .wav file in memory from a block's (unsigned 8-bit mono) pcm samples,
+// with the lead-in, channel count and bit depth of opts (see audio.DualChannel and audio.ToSigned16).
+func _blockWAV(blockData []byte, sampleRate int, opts PackageOptions) ([]byte, error) {
+	// optionally prepend the lead-in at the level pulses end on, so it leads into the first edge
+	if opts.LeadIn > 0 {
+		blockData = append(bytes.Repeat([]byte{audio.LeadInLevel(opts.amplitude(), opts.Polarity)}, opts.LeadIn), blockData...)
+	}
+	if opts.channels() == 2 {
+		blockData = audio.DualChannel(blockData, opts.InvertRight)
+	}
+	if opts.bitsPerSample() == 16 {
+		blockData = audio.ToSigned16(blockData)
+	}
+	wavBuffer := new(bytes.Buffer)
+	if err := audio.WriteWAVHeader(wavBuffer, sampleRate, opts.bitsPerSample(), opts.channels(), len(blockData), nil); err != nil {
+		return nil, fmt.Errorf("error writing wav header: %w", err)
+	}
+	if _, err := wavBuffer.Write(blockData); err != nil {
+		return nil, fmt.Errorf("error writing wav data: %w", err)
+	}
+	return wavBuffer.Bytes(), nil
+}
@@ -0,0 +1,56 @@
+// internal/export/block_render_test.go
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/testutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderBlockWAVMatchesPackage(t *testing.T) {
+	testutil.Quiet(t)
+	pcm, indexData := _processTestTAP(t, 2, 500, audio.ProcessOptions{})
+	optionSets := []PackageOptions{
+		{},
+		{LeadIn: 32},
+		{LeadIn: 16, BitsPerSample: 16, Channels: 2, InvertRight: true},
+		{GroupPolicy: GroupTight},
+	}
+	for n, opts := range optionSets {
+		t.Run(fmt.Sprintf("options %d", n), func(t *testing.T) {
+			base := filepath.Join(t.TempDir(), "render")
+			blockCount, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := _readCPKFiles(base + ".cpk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := _parseBlocksCSV(files["blocks.csv"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != blockCount || blockCount == 0 {
+				t.Fatalf("blocks.csv has %d rows for %d blocks", len(rows), blockCount)
+			}
+			for block, row := range rows {
+				wavData, err := RenderBlockWAV(pcm, indexData, block, constants.SampleRate, opts)
+				if err != nil {
+					t.Fatalf("block %d: %v", block, err)
+				}
+				if !bytes.Equal(wavData, files[row.file]) {
+					t.Errorf("block %d differs from %s in the package (%d vs %d bytes)", block, row.file, len(wavData), len(files[row.file]))
+				}
+			}
+			if _, err := RenderBlockWAV(pcm, indexData, blockCount, constants.SampleRate, opts); err == nil {
+				t.Errorf("block %d beyond the last block rendered without error", blockCount)
+			}
+		})
+	}
+}
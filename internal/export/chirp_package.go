@@ -6,11 +6,12 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"go_chirp_the_tap/internal/audio"
 	"go_chirp_the_tap/internal/constants"
-	"os"
+	"io"
 	"path/filepath" // needed for manifest (base)
 	"time"          // needed for manifest timestamp
 )
@@ -18,35 +19,108 @@ import (
 // PackageManifest defines the structure for the package_manifest.json file
 // included within the .cpk archive.
 type PackageManifest struct {
-	TargetSystem       string  `json:"target_system"`         // placeholder
-	ClockStandard      string  `json:"clock_standard"`        // "pal", "ntsc", or "unknown"
-	ClockFrequency     float64 `json:"clock_frequency"`       // cpu clock frequency in hz used for processing
-	SampleRate         int     `json:"sample_rate"`           // audio sample rate in hz
-	SourceFile         string  `json:"source_file"`           // base name of the original .tap file
-	Polarity           string  `json:"polarity"`              // signal polarity used
-	Waveform           string  `json:"waveform"`              // waveform used for pulses (only square atm)
-	AudioBitsPerSample int     `json:"audio_bits_per_sample"` // bits per audio sample (e.g., 8)
-	AudioChannels      int     `json:"audio_channels"`        // number of audio channels (e.g., 1 for mono)
-	CreationTimestamp  string  `json:"creation_timestamp"`    // timestamp when the cpk file was created
+	TargetSystem       string  `json:"target_system"`                    // placeholder
+	ClockStandard      string  `json:"clock_standard"`                   // "pal", "ntsc", or "unknown"
+	ClockFrequency     float64 `json:"clock_frequency"`                  // cpu clock frequency in hz used for processing
+	SampleRate         int     `json:"sample_rate"`                      // audio sample rate in hz
+	SourceFile         string  `json:"source_file"`                      // base name of the original .tap file
+	Polarity           string  `json:"polarity"`                         // signal polarity used
+	Waveform           string  `json:"waveform"`                         // waveform used for pulses: square, sine or triangle
+	Amplitude          int     `json:"amplitude,omitempty"`              // pulse amplitude (1-127) around the 128 dc offset; missing in older packages (127)
+	AudioBitsPerSample int     `json:"audio_bits_per_sample"`            // bits per audio sample (e.g., 8)
+	AudioChannels      int     `json:"audio_channels"`                   // number of audio channels (e.g., 1 for mono)
+	CreationTimestamp  string  `json:"creation_timestamp"`               // timestamp when the cpk file was created
+	BlockLeadInSamples int     `json:"block_lead_in_samples,omitempty"`  // pulse end level samples prepended to every block .wav (not in blocks.csv times)
+	UpdatedTimestamp   string  `json:"updated_timestamp,omitempty"`      // timestamp when blocks were last appended (see AppendToCPK)
+	LeaderSilence      float64 `json:"leader_silence_seconds,omitempty"` // blank tape before the first signal in seconds (see audio.LeaderSilence)
+	LeaderTrimmed      bool    `json:"leader_trimmed,omitempty"`         // true if that blank tape was trimmed from the audio
+	RightInverted      bool    `json:"right_channel_inverted,omitempty"` // stereo blocks only: the right channel is the phase-inverted left one
+}
+
+// PackageOptions holds optional settings for SplitAndPackageBlocks.
+// the zero value produces a single .cpk file.
+type PackageOptions struct {
+	SplitSize     int64          // if > 0, split the archive into volumes of at most SplitSize bytes (<name>.cpk.001, ...)
+	Checksums     bool           // if true, add checksums.txt with the sha256 of every block .wav file (sha256sum format)
+	SourceIDX     []byte         // if set, the raw .idx file stored as source.idx to keep the original labelling source
+	Sink          OutputSink     // where the .cpk file (or its volumes) is created; nil writes to the filesystem
+	LeadIn        int            // if > 0, prepend this many samples at the pulse end level (see audio.LeadInLevel) to every block .wav so its first pulse starts with a clean edge
+	GroupPolicy   GroupPolicy    // how index entries are grouped into block .wav files; "" uses GroupLoose
+	TrimmedLeader int            // samples of blank leader trimmed from the audio before packaging, recorded in the manifest
+	CSVOrder      BlockOrder     // row order of blocks.csv; "" keeps the position order
+	BitsPerSample int            // bits per sample of the block .wav files: 8 (unsigned, also for 0) or 16 (signed, widened with audio.ToSigned16)
+	Channels      int            // channels of the block .wav files: 1 (also for 0) or 2 (the signal in both channels)
+	InvertRight   bool           // with 2 channels, phase-invert the right channel (see audio.DualChannel)
+	Polarity      audio.Polarity // signal polarity the audio was generated with, recorded in the manifest; "" is normal
+	Waveform      audio.Waveform // pulse waveform the audio was generated with, recorded in the manifest; "" is square
+	Amplitude     byte           // pulse amplitude the audio was generated with, recorded in the manifest and used for the lead-in; 0 is 127
+}
+
+// bitsPerSample returns the bit depth of the block .wav files (8 unless set to 16).
+func (o PackageOptions) bitsPerSample() int {
+	if o.BitsPerSample == 16 {
+		return 16
+	}
+	return 8
+}
+
+// polarity returns the manifest name of the signal polarity ("normal" unless inverted).
+func (o PackageOptions) polarity() string {
+	if o.Polarity == "" {
+		return string(audio.PolarityNormal)
+	}
+	return string(o.Polarity)
+}
+
+// waveform returns the manifest name of the pulse waveform ("square" unless set).
+func (o PackageOptions) waveform() string {
+	if o.Waveform == "" {
+		return string(audio.WaveSquare)
+	}
+	return string(o.Waveform)
+}
+
+// amplitude returns the pulse amplitude (127 unless set).
+func (o PackageOptions) amplitude() byte {
+	if o.Amplitude == 0 || o.Amplitude > 127 {
+		return 127
+	}
+	return o.Amplitude
+}
+
+// channels returns the channel count of the block .wav files (1 unless set to 2).
+func (o PackageOptions) channels() int {
+	if o.Channels == 2 {
+		return 2
+	}
+	return 1
 }
 
 // SplitAndPackageBlocks generates a .cpk archive (gzipped tarball).
 // the archive contains a manifest file (package_manifest.json), a block index (blocks.csv),
-// and individual audio blocks as separate .wav files based on the provided indexData.
-func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, baseFilePath string, sampleRate int, selectedClock float64, targetSystem string) (err error) {
+// a playlist of the blocks (playlist.m3u) and individual audio blocks as separate .wav files
+// based on the provided indexData. it returns the number of block .wav files written.
+func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, baseFilePath string, sampleRate int, selectedClock float64, targetSystem string, opts PackageOptions) (blockCount int, err error) {
 	if sampleRate <= 0 {
-		return fmt.Errorf("invalid sample rate: %d", sampleRate)
+		return 0, fmt.Errorf("invalid sample rate: %d", sampleRate)
 	}
-	floatSampleRate := float64(sampleRate)
 
+	// output goes to a single file, or to size-limited volumes if a split size is set
 	outPath := baseFilePath + ".cpk"
-	file, err := os.Create(outPath)
+	sink := _sinkOrDefault(opts.Sink)
+	var file io.WriteCloser
+	if opts.SplitSize > 0 {
+		file, err = _newVolumeWriter(sink, outPath, opts.SplitSize)
+	} else {
+		file, err = sink.Create(outPath)
+	}
 	if err != nil {
-		return fmt.Errorf("error creating output file %s: %w", outPath, err)
+		return 0, fmt.Errorf("error creating output file %s: %w", outPath, err)
 	}
 	// setup defer for closing file, check error later using named return 'err'
 	defer func() {
-		closeErr := file.Close()
+		// a failed archive is discarded instead of moved into place
+		closeErr := _closeOrAbort(file, err != nil)
 		if err == nil && closeErr != nil { // only overwrite err if no previous error occurred
 			err = fmt.Errorf("error closing output file %s: %w", outPath, closeErr)
 		} else if closeErr != nil {
@@ -55,10 +129,36 @@ func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, base
 		}
 	}()
 
+	// write the archive content
+	blockCount, err = WritePackage(file, pcmSamples, indexData, filepath.Base(baseFilePath+".tap"), sampleRate, selectedClock, targetSystem, opts)
+	if err != nil {
+		return blockCount, err
+	}
+
+	if opts.SplitSize > 0 {
+		fmt.Printf("created archive with %d blocks, manifest, and csv: %s (split into volumes of max %d bytes)\n", blockCount, outPath, opts.SplitSize)
+	} else {
+		fmt.Printf("created archive with %d blocks, manifest, and csv: %s\n", blockCount, outPath)
+	}
+	// note: defer handles closing the file; errors captured by named return 'err'
+	return blockCount, err // return the first error encountered during processing or closing (or nil if success)
+}
+
+// WritePackage writes the .cpk archive content (gzipped tarball with manifest, blocks.csv,
+// playlist.m3u and block .wav files) to w and returns the number of block .wav files written.
+// sourceFile is the base name of the original .tap file recorded in the manifest.
+// opts.SplitSize is ignored here, the caller decides where w goes.
+// w is not closed; SplitAndPackageBlocks uses this to write .cpk files.
+func WritePackage(w io.Writer, pcmSamples []byte, indexData []audio.IndexEntry, sourceFile string, sampleRate int, selectedClock float64, targetSystem string, opts PackageOptions) (blockCount int, err error) {
+	if sampleRate <= 0 {
+		return 0, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	floatSampleRate := float64(sampleRate)
+
 	// setup gzip and tar writers - note file compression is set to 7 for high compression and bearable speed
-	gzWriter, err := gzip.NewWriterLevel(file, 7)
+	gzWriter, err := gzip.NewWriterLevel(w, 7)
 	if err != nil {
-		return fmt.Errorf("error creating gzip writer: %w", err)
+		return blockCount, fmt.Errorf("error creating gzip writer: %w", err)
 	}
 	tarWriter := tar.NewWriter(gzWriter)
 
@@ -85,12 +185,17 @@ func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, base
 		TargetSystem:       targetSystem,
 		ClockFrequency:     selectedClock,
 		SampleRate:         sampleRate,
-		SourceFile:         filepath.Base(baseFilePath + ".tap"),
-		Polarity:           "normal", // hardcoded assumption for now...
-		Waveform:           "square",
-		AudioBitsPerSample: 8,
-		AudioChannels:      1,
+		SourceFile:         sourceFile,
+		Polarity:           opts.polarity(),
+		Waveform:           opts.waveform(),
+		Amplitude:          int(opts.amplitude()),
+		AudioBitsPerSample: opts.bitsPerSample(),
+		AudioChannels:      opts.channels(),
 		CreationTimestamp:  time.Now().UTC().Format(time.RFC3339),
+		BlockLeadInSamples: max(opts.LeadIn, 0),
+		LeaderSilence:      float64(audio.LeaderSilence(indexData)+max(opts.TrimmedLeader, 0)) / floatSampleRate,
+		LeaderTrimmed:      opts.TrimmedLeader > 0,
+		RightInverted:      opts.channels() == 2 && opts.InvertRight,
 	}
 
 	// determine clock standard string ("PAL" or "NTSC") based on exact frequency value.
@@ -104,31 +209,56 @@ func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, base
 		fmt.Printf("warning: unexpected clock frequency %f processed; setting standard to unknown.\n", selectedClock)
 	}
 
+	// a volume writer (split archive) may start a new volume at the boundaries between entries
+	boundary := func() error { return nil }
+	if bw, ok := w.(_boundaryWriter); ok {
+		boundary = func() error {
+			// complete the tar entry and push the compressed data out before marking the boundary
+			if err := tarWriter.Flush(); err != nil {
+				return fmt.Errorf("error flushing tar writer: %w", err)
+			}
+			if err := gzWriter.Flush(); err != nil {
+				return fmt.Errorf("error flushing gzip writer: %w", err)
+			}
+			return bw.Boundary()
+		}
+	}
+
 	manifestData, err := json.MarshalIndent(manifest, "", "  ") // pretty json
 	if err != nil {
-		return fmt.Errorf("error marshaling manifest to json: %w", err)
+		return blockCount, fmt.Errorf("error marshaling manifest to json: %w", err)
 	}
 	// write manifest to tar archive
 	manifestHeader := &tar.Header{Name: "package_manifest.json", Size: int64(len(manifestData)), Mode: 0644, ModTime: time.Now()}
 	if err = tarWriter.WriteHeader(manifestHeader); err != nil { // assign to existing err
-		return fmt.Errorf("error writing manifest tar header: %w", err)
+		return blockCount, fmt.Errorf("error writing manifest tar header: %w", err)
 	}
 	if _, err = tarWriter.Write(manifestData); err != nil { // assign to existing err
-		return fmt.Errorf("error writing manifest json to tar: %w", err)
+		return blockCount, fmt.Errorf("error writing manifest json to tar: %w", err)
+	}
+	if err = boundary(); err != nil {
+		return blockCount, err
 	}
 	fmt.Println("manifest data written to archive.")
 
 	// generate csv data in memory (blocks.csv)
 	// passing "" as path and true for in-memory generation indicates it's for the archive
 	fmt.Println("creating csv data...")
-	csvData, err := ExportBlockInfo(indexData, "", floatSampleRate)
+	csvData, err := ExportBlockInfo(indexData, "", floatSampleRate, opts.GroupPolicy, opts.CSVOrder)
 	if err != nil {
-		return fmt.Errorf("error generating csv data for package: %w", err)
+		return blockCount, fmt.Errorf("error generating csv data for package: %w", err)
 	}
 
 	// process index entries and write individual wav blocks to tar archive
+	// generate playlist data in memory (playlist.m3u) listing the block wavs in order
+	fmt.Println("creating playlist data...")
+	playlistData, err := ExportPlaylist(indexData, "", floatSampleRate, opts.GroupPolicy)
+	if err != nil {
+		return blockCount, fmt.Errorf("error generating playlist data for package: %w", err)
+	}
+
 	fmt.Printf("processing %d index entries to create audio blocks...\n", len(indexData))
-	blockCount := 0
+	checksums := new(bytes.Buffer) // sha256sum style lines for checksums.txt (if enabled)
 	processedEntries := 0
 	i := 0
 	for i < len(indexData) {
@@ -138,66 +268,42 @@ func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, base
 		}
 
 		// analyze current index entry(ies) to identify next logical block
-		groupInfo := _getGroupedBlockInfo(indexData, i, floatSampleRate)
+		groupInfo := _getGroupedBlockInfo(indexData, i, floatSampleRate, opts.GroupPolicy)
 
 		// if a valid exportable block was identified by the analyzer...
 		if groupInfo.IsBlock {
 			// format filename like block_000_lead.wav, block_001_data.wav etc.
 			wavFileName := fmt.Sprintf("block_%03d_%s.wav", blockCount, groupInfo.BlockType)
-			blockStartSample := groupInfo.StartEntry.StartSample
-			// add +1 to EndSample because slice range notation [start:end] is exclusive at the 'end' index
-			blockEndSampleIndex := groupInfo.EndEntry.EndSample + 1
-
-			// basic checks for sample indices
-			if blockStartSample >= 0 && blockEndSampleIndex > blockStartSample {
-				// ensure indices are within the bounds of the source pcmSamples slice
-				if blockStartSample >= len(pcmSamples) {
-					fmt.Printf("warning: block %d start sample %d out of bounds (pcm len %d), skipping.\n", blockCount, blockStartSample, len(pcmSamples))
-					i += groupInfo.ConsumedEntries
-					processedEntries += groupInfo.ConsumedEntries
-					continue // continue to next iteration of outer loop
-				}
-				// cap end index if it goes beyond available pcm data (e.g., due to rounding)
-				if blockEndSampleIndex > len(pcmSamples) {
-					fmt.Printf("warning: block %d end sample %d out of bounds (pcm len %d), truncating.\n", blockCount, groupInfo.EndEntry.EndSample, len(pcmSamples))
-					blockEndSampleIndex = len(pcmSamples)
-				}
-
-				// extract the pcm data slice for this block
-				blockData := pcmSamples[blockStartSample:blockEndSampleIndex]
-
-				// skip writing if the extracted block data is empty
-				if len(blockData) == 0 {
-					fmt.Printf("warning: block %d (%s) resulted in zero samples after slicing, skipping.\n", blockCount, wavFileName)
-					i += groupInfo.ConsumedEntries
-					processedEntries += groupInfo.ConsumedEntries
-					continue // continue to next iteration of outer loop
-				}
-
-				// write this block as a separate wav file into the tar archive
-				wavBuffer := new(bytes.Buffer) // use in-memory buffer to build wav file first
-				// write header to buffer
-				if err = audio.WriteWAVHeader(wavBuffer, sampleRate, len(blockData)); err != nil { // assign to existing err
-					return fmt.Errorf("error writing wav header for %s: %w", wavFileName, err)
-				}
-				// write pcm data to buffer
-				if _, err = wavBuffer.Write(blockData); err != nil { // assign to existing err
-					return fmt.Errorf("error writing wav data for %s: %w", wavFileName, err)
-				}
-				// write buffer content to tar archive
-				tarHeader := &tar.Header{Name: wavFileName, Size: int64(wavBuffer.Len()), Mode: 0644, ModTime: time.Now()}
-				if err = tarWriter.WriteHeader(tarHeader); err != nil { // assign to existing err
-					return fmt.Errorf("error writing tar header for %s: %w", wavFileName, err)
-				}
-				if _, err = tarWriter.Write(wavBuffer.Bytes()); err != nil { // assign to existing err
-					return fmt.Errorf("error writing wav data to tar for %s: %w", wavFileName, err)
-				}
-				blockCount++ // increment successful block count
-
-			} else {
-				// log if sample range derived from groupInfo was invalid
-				fmt.Printf("warning: invalid sample range for identified block starting near index %d (%s): start=%d, end=%d. skipping.\n", i, groupInfo.BlockType, blockStartSample, groupInfo.EndEntry.EndSample)
+
+			// extract the pcm data slice for this block, skipping blocks with unusable sample ranges
+			blockData, sliceErr := _sliceBlockPCM(pcmSamples, groupInfo, blockCount)
+			if sliceErr != nil {
+				fmt.Printf("warning: %v (near index %d, %s), skipping.\n", sliceErr, i, wavFileName)
+				i += groupInfo.ConsumedEntries
+				processedEntries += groupInfo.ConsumedEntries
+				continue // continue to next iteration of outer loop
+			}
+
+			// write this block as a separate wav file into the tar archive
+			var wavData []byte
+			if wavData, err = _blockWAV(blockData, sampleRate, opts); err != nil { // build wav file in memory first, assign to existing err
+				return blockCount, fmt.Errorf("error writing wav for %s: %w", wavFileName, err)
+			}
+			// write wav content to tar archive
+			tarHeader := &tar.Header{Name: wavFileName, Size: int64(len(wavData)), Mode: 0644, ModTime: time.Now()}
+			if err = tarWriter.WriteHeader(tarHeader); err != nil { // assign to existing err
+				return blockCount, fmt.Errorf("error writing tar header for %s: %w", wavFileName, err)
 			}
+			if _, err = tarWriter.Write(wavData); err != nil { // assign to existing err
+				return blockCount, fmt.Errorf("error writing wav data to tar for %s: %w", wavFileName, err)
+			}
+			if opts.Checksums {
+				fmt.Fprintf(checksums, "%x  %s\n", sha256.Sum256(wavData), wavFileName)
+			}
+			if err = boundary(); err != nil {
+				return blockCount, err
+			}
+			blockCount++ // increment successful block count
 		} // end if groupInfo.IsBlock
 
 		// advance main loop index by number of entries consumed by the analyzer (1 or 2)
@@ -209,15 +315,48 @@ func SplitAndPackageBlocks(pcmSamples []byte, indexData []audio.IndexEntry, base
 	fmt.Println("writing csv data to archive...")
 	csvHeader := &tar.Header{Name: "blocks.csv", Size: int64(len(csvData)), Mode: 0644, ModTime: time.Now()}
 	if err = tarWriter.WriteHeader(csvHeader); err != nil { // assign to existing err
-		return fmt.Errorf("error writing csv tar header: %w", err)
+		return blockCount, fmt.Errorf("error writing csv tar header: %w", err)
 	}
 	if _, err = tarWriter.Write(csvData); err != nil { // assign to existing err
-		return fmt.Errorf("error writing csv to tar: %w", err)
+		return blockCount, fmt.Errorf("error writing csv to tar: %w", err)
+	}
+
+	// write generated playlist to the tar archive (playlist.m3u)
+	fmt.Println("writing playlist data to archive...")
+	playlistHeader := &tar.Header{Name: "playlist.m3u", Size: int64(len(playlistData)), Mode: 0644, ModTime: time.Now()}
+	if err = tarWriter.WriteHeader(playlistHeader); err != nil { // assign to existing err
+		return blockCount, fmt.Errorf("error writing playlist tar header: %w", err)
+	}
+	if _, err = tarWriter.Write(playlistData); err != nil { // assign to existing err
+		return blockCount, fmt.Errorf("error writing playlist to tar: %w", err)
+	}
+
+	// write the original idx file to the tar archive (source.idx)
+	if len(opts.SourceIDX) > 0 {
+		fmt.Println("writing source idx to archive...")
+		idxHeader := &tar.Header{Name: "source.idx", Size: int64(len(opts.SourceIDX)), Mode: 0644, ModTime: time.Now()}
+		if err = tarWriter.WriteHeader(idxHeader); err != nil { // assign to existing err
+			return blockCount, fmt.Errorf("error writing source idx tar header: %w", err)
+		}
+		if _, err = tarWriter.Write(opts.SourceIDX); err != nil { // assign to existing err
+			return blockCount, fmt.Errorf("error writing source idx to tar: %w", err)
+		}
+	}
+
+	// write block checksums to the tar archive (checksums.txt)
+	if opts.Checksums {
+		fmt.Println("writing block checksums to archive...")
+		checksumsHeader := &tar.Header{Name: "checksums.txt", Size: int64(checksums.Len()), Mode: 0644, ModTime: time.Now()}
+		if err = tarWriter.WriteHeader(checksumsHeader); err != nil { // assign to existing err
+			return blockCount, fmt.Errorf("error writing checksums tar header: %w", err)
+		}
+		if _, err = tarWriter.Write(checksums.Bytes()); err != nil { // assign to existing err
+			return blockCount, fmt.Errorf("error writing checksums to tar: %w", err)
+		}
 	}
 
-	fmt.Printf("created archive with %d blocks, manifest, and csv: %s\n", blockCount, outPath)
-	// note: defer handles closing writers and file; errors captured by named return 'err'
-	return err // return the first error encountered during processing or closing (or nil if success)
+	// note: defer handles closing writers; errors captured by named return 'err'
+	return blockCount, err
 }
 
 // note: _getGroupedBlockInfo (from block_analyzer.go) and ExportBlockInfo from csv.go
@@ -0,0 +1,175 @@
+// internal/export/chirp_package_test.go
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/testutil"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// _discardSink is an OutputSink that drops everything written to it.
+type _discardSink struct{}
+
+func (_discardSink) Create(name string) (io.WriteCloser, error) {
+	return _nopCloser{io.Discard}, nil
+}
+
+type _nopCloser struct{ io.Writer }
+
+func (_nopCloser) Close() error { return nil }
+
+// _processTestTAP converts a synthetic v1 tape with the given number of files into pcm and index data.
+func _processTestTAP(tb testing.TB, files, dataBytes int, opts audio.ProcessOptions) ([]byte, []audio.IndexEntry) {
+	tb.Helper()
+	tapData := testutil.TAP(1, testutil.MultiBlockPayload(files, dataBytes))
+	pcm, indexData, err := audio.ProcessTAPData(tapData, 1, constants.ClockPAL, constants.SampleRate, nil, opts)
+	if err != nil {
+		tb.Fatalf("error processing test tap: %v", err)
+	}
+	return pcm, indexData
+}
+
+func BenchmarkSplitAndPackageBlocks(b *testing.B) {
+	testutil.Quiet(b)
+	pcm, indexData := _processTestTAP(b, 4, 20000, audio.ProcessOptions{})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(pcm)))
+	for range b.N {
+		_, err := SplitAndPackageBlocks(pcm, indexData, "bench", constants.SampleRate, constants.ClockPAL, "c64", PackageOptions{Sink: _discardSink{}})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBlockLeadInFollowsPolarity(t *testing.T) {
+	testutil.Quiet(t)
+	const leadIn = 24
+	for _, polarity := range []audio.Polarity{audio.PolarityNormal, audio.PolarityInverted} {
+		t.Run(string(polarity), func(t *testing.T) {
+			pcm, indexData := _processTestTAP(t, 2, 500, audio.ProcessOptions{Polarity: polarity})
+			base := filepath.Join(t.TempDir(), "polarity")
+			opts := PackageOptions{LeadIn: leadIn, Polarity: polarity}
+			if _, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", opts); err != nil {
+				t.Fatal(err)
+			}
+			if polarity == audio.PolarityInverted {
+				_checkBlockLeadIns(t, base+".cpk", leadIn, 255, 1)
+			} else {
+				_checkBlockLeadIns(t, base+".cpk", leadIn, 1, 255)
+			}
+		})
+	}
+}
+
+func TestBlockLeadInFollowsAmplitude(t *testing.T) {
+	testutil.Quiet(t)
+	const leadIn, amp = 24, 40
+	for _, polarity := range []audio.Polarity{audio.PolarityNormal, audio.PolarityInverted} {
+		t.Run(string(polarity), func(t *testing.T) {
+			pcm, indexData := _processTestTAP(t, 1, 500, audio.ProcessOptions{Amplitude: amp, Polarity: polarity})
+			base := filepath.Join(t.TempDir(), "amplitude")
+			opts := PackageOptions{LeadIn: leadIn, Amplitude: amp, Polarity: polarity}
+			if _, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", opts); err != nil {
+				t.Fatal(err)
+			}
+			if polarity == audio.PolarityInverted {
+				_checkBlockLeadIns(t, base+".cpk", leadIn, 128+amp, 128-amp)
+			} else {
+				_checkBlockLeadIns(t, base+".cpk", leadIn, 128-amp, 128+amp)
+			}
+
+			files, err := _readCPKFiles(base + ".cpk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest PackageManifest
+			if err := json.Unmarshal(files["package_manifest.json"], &manifest); err != nil || manifest.Amplitude != amp {
+				t.Errorf("manifest amplitude %d (error %v), want %d", manifest.Amplitude, err, amp)
+			}
+		})
+	}
+}
+
+// _checkBlockLeadIns checks that every block .wav of the 8-bit mono package at cpkPath starts
+// with leadIn samples at endLevel (the level pulses end on), followed by a step to firstLevel.
+func _checkBlockLeadIns(t *testing.T, cpkPath string, leadIn int, endLevel, firstLevel byte) {
+	t.Helper()
+	files, err := _readCPKFiles(cpkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := _parseBlocksCSV(files["blocks.csv"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		reader := bytes.NewReader(files[row.file])
+		if _, _, _, _, err := audio.ParseWAVHeader(reader); err != nil {
+			t.Fatalf("%s: %v", row.file, err)
+		}
+		samples := make([]byte, reader.Len())
+		reader.Read(samples)
+		if len(samples) <= leadIn {
+			t.Fatalf("%s: only %d samples", row.file, len(samples))
+		}
+		for i, sample := range samples[:leadIn] {
+			if sample != endLevel {
+				t.Fatalf("%s: lead-in sample %d is %d, want %d", row.file, i, sample, endLevel)
+			}
+		}
+		if samples[leadIn] != firstLevel {
+			t.Errorf("%s: first edge steps from %d to %d, want %d", row.file, endLevel, samples[leadIn], firstLevel)
+		}
+	}
+}
+
+func TestPackage16BitBlocks(t *testing.T) {
+	testutil.Quiet(t)
+	pcm, indexData := _processTestTAP(t, 1, 500, audio.ProcessOptions{})
+	base := filepath.Join(t.TempDir(), "wide")
+	if _, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", PackageOptions{BitsPerSample: 16}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := _readCPKFiles(base + ".cpk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest PackageManifest
+	if err := json.Unmarshal(files["package_manifest.json"], &manifest); err != nil || manifest.AudioBitsPerSample != 16 {
+		t.Errorf("manifest bits per sample %d (error %v), want 16", manifest.AudioBitsPerSample, err)
+	}
+	rows, err := _parseBlocksCSV(files["blocks.csv"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		data := files[row.file]
+		sampleRate, bits, channels, dataSize, err := audio.ParseWAVHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", row.file, err)
+		}
+		if bits != 16 || channels != 1 || sampleRate != constants.SampleRate || dataSize%2 != 0 {
+			t.Fatalf("%s: %d-bit, %d channels, %d hz, %d bytes; want 16-bit mono at %d hz", row.file, bits, channels, sampleRate, dataSize, int(constants.SampleRate))
+		}
+		if blockAlign, byteRate := binary.LittleEndian.Uint16(data[32:34]), binary.LittleEndian.Uint32(data[28:32]); blockAlign != 2 || byteRate != 2*constants.SampleRate {
+			t.Errorf("%s: block align %d, byte rate %d; want 2 and %d", row.file, blockAlign, byteRate, int(2*constants.SampleRate))
+		}
+		// widened 8-bit levels: full-scale pulses at +/-32512, nothing beyond
+		minSample, maxSample := int16(0), int16(0)
+		for i := len(data) - dataSize; i < len(data); i += 2 {
+			sample := int16(binary.LittleEndian.Uint16(data[i:]))
+			minSample, maxSample = min(minSample, sample), max(maxSample, sample)
+		}
+		if minSample != -32512 || maxSample != 32512 {
+			t.Errorf("%s: samples range from %d to %d, want -32512 to 32512", row.file, minSample, maxSample)
+		}
+	}
+}
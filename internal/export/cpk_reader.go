@@ -0,0 +1,82 @@
+// internal/export/cpk_reader.go
+
+package export
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CPKReader reads the members of a .cpk package (gzip compressed tar) one after another
+// without extracting them, like tar.Reader: Next advances to the next member and Read reads
+// its content. members that are not read are skipped, so callers can pick e.g. just the
+// manifest and blocks.csv without materialising the block wavs.
+type CPKReader struct {
+	path      string
+	file      *os.File
+	gzReader  *gzip.Reader
+	tarReader *tar.Reader
+	current   string // name of the current member
+}
+
+// OpenCPK opens the .cpk package at cpkPath for reading. the caller must Close it.
+func OpenCPK(cpkPath string) (*CPKReader, error) {
+	file, err := os.Open(cpkPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cpk package %s: %w", cpkPath, err)
+	}
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, _cpkReadError(cpkPath, "error reading gzip stream", err)
+	}
+
+	return &CPKReader{path: cpkPath, file: file, gzReader: gzReader, tarReader: tar.NewReader(gzReader)}, nil
+}
+
+// Next advances to the next member of the package and returns its header.
+// it returns io.EOF at the end of the archive.
+func (r *CPKReader) Next() (*tar.Header, error) {
+	header, err := r.tarReader.Next()
+	if err == io.EOF {
+		// drain the gzip stream so its trailer (checksum and size) is verified too
+		if _, err := io.Copy(io.Discard, r.gzReader); err != nil {
+			return nil, _cpkReadError(r.path, "error reading gzip stream", err)
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, _cpkReadError(r.path, "error reading tar archive", err)
+	}
+	r.current = header.Name
+	return header, nil
+}
+
+// Read reads from the content of the current member.
+func (r *CPKReader) Read(p []byte) (int, error) {
+	n, err := r.tarReader.Read(p)
+	if err != nil && err != io.EOF {
+		return n, _cpkReadError(r.path, "error reading "+r.current, err)
+	}
+	return n, err
+}
+
+// Close closes the package file.
+func (r *CPKReader) Close() error {
+	r.gzReader.Close()
+	return r.file.Close()
+}
+
+// _cpkReadError wraps a read error of the package at cpkPath, reporting a truncated archive
+// (unexpected end of the gzip or tar stream) as such.
+func _cpkReadError(cpkPath, context string, err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("cpk package %s is truncated (%s): %w", cpkPath, context, err)
+	}
+	return fmt.Errorf("%s of %s: %w", context, cpkPath, err)
+}
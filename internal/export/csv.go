@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"go_chirp_the_tap/internal/audio"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 )
@@ -23,64 +24,178 @@ import (
 //   - outputPath: The file path to write the .csv to. If this string is empty, the function
 //     will not write to disk.
 //   - sampleRate: The audio sample rate, required for accurately calculating block end times.
+//   - policy: How index entries are grouped into blocks (see GroupPolicy).
+//   - order: Row order of the table (see BlockOrder); file names keep the block numbers.
 //
 // returns:
 //   - []byte: A byte slice containing the formatted csv data, which is always returned.
 //   - error: An error if any part of the generation or file writing process fails.
-func ExportBlockInfo(indexData []audio.IndexEntry, outputPath string, sampleRate float64) ([]byte, error) {
+func ExportBlockInfo(indexData []audio.IndexEntry, outputPath string, sampleRate float64, policy GroupPolicy, order BlockOrder) ([]byte, error) {
 	if sampleRate <= 0 {
 		return nil, fmt.Errorf("invalid sample rate: %f", sampleRate)
 	}
 
-	csvBuffer := new(bytes.Buffer)
-	w := tabwriter.NewWriter(csvBuffer, 0, 8, 2, ' ', 0)
-
-	// generate human-readable table with | for visual separated with leading and trailing tab
-	_, err := fmt.Fprintln(w, "start_time\t|\tend_time\t|\tblock\t|\tidx_tag\t|\thex_start_time\t|\tfile\t")
+	rows := _blockRows(indexData, sampleRate, policy, 0, 0)
+	if err := _sortBlockRows(rows, order); err != nil {
+		return nil, err
+	}
+	csvData, err := _renderBlocksCSV(rows)
 	if err != nil {
-		return nil, fmt.Errorf("error writing csv header: %w", err)
+		return nil, err
+	}
+
+	// write file
+	if outputPath != "" {
+		if err := os.WriteFile(outputPath, csvData, 0644); err != nil {
+			return nil, fmt.Errorf("error writing csv file %s: %w", outputPath, err)
+		}
 	}
 
-	blockCount := 0
+	return csvData, nil
+}
+
+// BlockOrder selects the row order of blocks.csv. the block numbers in the file names always
+// follow the position order, so they keep matching the archive's block files.
+type BlockOrder string
+
+const (
+	OrderPosition BlockOrder = "position" // tape order (default)
+	OrderType     BlockOrder = "type"     // grouped by block type, tape order within each type
+	OrderName     BlockOrder = "name"     // by idx tag, untagged blocks last, tape order for equal tags
+)
+
+// ParseBlockOrder validates a block order name ("position", "type" or "name").
+func ParseBlockOrder(name string) (BlockOrder, error) {
+	switch order := BlockOrder(name); order {
+	case OrderPosition, OrderType, OrderName:
+		return order, nil
+	}
+	return "", fmt.Errorf("unsupported block order: %s (use 'position', 'type' or 'name')", name)
+}
+
+// _sortBlockRows reorders rows (built in position order) according to order; "" keeps them.
+func _sortBlockRows(rows []_blocksCSVRow, order BlockOrder) error {
+	switch order {
+	case "", OrderPosition:
+	case OrderType:
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].blockType < rows[j].blockType })
+	case OrderName:
+		sort.SliceStable(rows, func(i, j int) bool {
+			if (rows[i].idxTag == "") != (rows[j].idxTag == "") {
+				return rows[j].idxTag == "" // tagged blocks first
+			}
+			return strings.ToLower(rows[i].idxTag) < strings.ToLower(rows[j].idxTag)
+		})
+	default:
+		return fmt.Errorf("unsupported block order: %s", order)
+	}
+	return nil
+}
+
+// _blockRows builds the blocks.csv rows of the grouped blocks in indexData. block numbers in the
+// file names start at firstBlock and timeOffset (seconds) is added to all times, which lets
+// AppendToCPK continue an existing table.
+func _blockRows(indexData []audio.IndexEntry, sampleRate float64, policy GroupPolicy, firstBlock int, timeOffset float64) []_blocksCSVRow {
+	var rows []_blocksCSVRow
+	blockCount := firstBlock
 	i := 0
 	// loop through index entries, grouping them into logical blocks
 	for i < len(indexData) {
-		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate) // use helper
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy) // use helper
 
 		if groupInfo.IsBlock {
-			wavFileName := fmt.Sprintf("block_%03d_%s.wav", blockCount, groupInfo.BlockType)
-			hexStart := fmt.Sprintf("0x%08x", groupInfo.StartEntry.StartPosition)
 			// sanitize tag for tabs/newlines - better safe than sorry. people do mad stuff sometimes. bwbahbhaha
 			safeIDXTag := strings.ReplaceAll(groupInfo.StartEntry.IDXTag, "\t", " ")
 			safeIDXTag = strings.ReplaceAll(safeIDXTag, "\n", " ")
 			safeIDXTag = strings.ReplaceAll(safeIDXTag, "|", " ")
 
-			// write line to buffer - use \t for columns, | as visual separator and trailing tab + newline
-			_, err = fmt.Fprintf(w, "%.6f\t|\t%.6f\t|\t%s\t|\t%s\t|\t%s\t|\t%s\t\n",
-				groupInfo.StartEntry.StartTime,
-				groupInfo.BlockEndTime,
-				groupInfo.BlockType,
-				safeIDXTag,
-				hexStart,
-				wavFileName,
-			)
-			// error check per row
-			if err != nil {
-				return nil, fmt.Errorf("error writing csv data row %d: %w", blockCount, err)
-			}
-
+			rows = append(rows, _blocksCSVRow{
+				startTime: groupInfo.StartEntry.StartTime + timeOffset,
+				endTime:   groupInfo.BlockEndTime + timeOffset,
+				blockType: groupInfo.BlockType,
+				idxTag:    safeIDXTag,
+				hexStart:  fmt.Sprintf("0x%08x", groupInfo.StartEntry.StartPosition),
+				file:      fmt.Sprintf("block_%03d_%s.wav", blockCount, groupInfo.BlockType),
+			})
 			blockCount++
 		}
 		i += groupInfo.ConsumedEntries
 	}
+	return rows
+}
+
+// _renderBlocksCSV formats rows as the blocks.csv table.
+func _renderBlocksCSV(rows []_blocksCSVRow) ([]byte, error) {
+	csvBuffer := new(bytes.Buffer)
+	w := tabwriter.NewWriter(csvBuffer, 0, 8, 2, ' ', 0)
+
+	// generate human-readable table with | for visual separated with leading and trailing tab
+	_, err := fmt.Fprintln(w, "start_time\t|\tend_time\t|\tblock\t|\tidx_tag\t|\thex_start_time\t|\tfile\t")
+	if err != nil {
+		return nil, fmt.Errorf("error writing csv header: %w", err)
+	}
+
+	for n, row := range rows {
+		// write line to buffer - use \t for columns, | as visual separator and trailing tab + newline
+		_, err = fmt.Fprintf(w, "%.6f\t|\t%.6f\t|\t%s\t|\t%s\t|\t%s\t|\t%s\t\n",
+			row.startTime,
+			row.endTime,
+			row.blockType,
+			row.idxTag,
+			row.hexStart,
+			row.file,
+		)
+		// error check per row
+		if err != nil {
+			return nil, fmt.Errorf("error writing csv data row %d: %w", n, err)
+		}
+	}
 
 	// flush tabwriter to ensure all data is processed and aligned in the buffer
-	err = w.Flush()
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("error flushing tabwriter: %w", err)
+	}
+	return csvBuffer.Bytes(), nil
+}
+
+// ExportPulseInfo generates a formatted, human-readable .csv table with one row per
+// pulse (or pause) as recorded by audio.AnalysePulses, for deep timing analysis.
+//
+// if an outputPath is provided, the function has the side effect of writing the
+// generated data to that file path.
+func ExportPulseInfo(pulses []audio.PulseEntry, outputPath string, sampleRate float64) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %f", sampleRate)
+	}
+
+	csvBuffer := new(bytes.Buffer)
+	w := tabwriter.NewWriter(csvBuffer, 0, 8, 2, ' ', 0)
+
+	// same visual layout as the block table: | separated columns with leading and trailing tab
+	_, err := fmt.Fprintln(w, "hex_position\t|\tvalue\t|\ttype\t|\tcycles\t|\tstart_sample\t|\tend_sample\t|\tstart_time\t")
 	if err != nil {
+		return nil, fmt.Errorf("error writing csv header: %w", err)
+	}
+
+	for i, pulse := range pulses {
+		_, err = fmt.Fprintf(w, "0x%08x\t|\t0x%02x\t|\t%s\t|\t%d\t|\t%d\t|\t%d\t|\t%.6f\t\n",
+			pulse.Position,
+			pulse.Value,
+			pulse.Type,
+			pulse.Cycles,
+			pulse.StartSample,
+			pulse.EndSample,
+			float64(pulse.StartSample)/sampleRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error writing csv data row %d: %w", i, err)
+		}
+	}
+
+	if err = w.Flush(); err != nil {
 		return nil, fmt.Errorf("error flushing tabwriter: %w", err)
 	}
 
-	// write file
 	if outputPath != "" {
 		if err := os.WriteFile(outputPath, csvBuffer.Bytes(), 0644); err != nil {
 			return nil, fmt.Errorf("error writing csv file %s: %w", outputPath, err)
@@ -0,0 +1,80 @@
+// internal/export/info.go
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CPKMemberInfo describes a file stored in a .cpk package.
+type CPKMemberInfo struct {
+	Name string
+	Size int64 // uncompressed size in bytes
+}
+
+// CPKInfo summarises a .cpk package as read by InspectCPK.
+type CPKInfo struct {
+	Manifest   PackageManifest
+	Members    []CPKMemberInfo // all files in archive order
+	Blocks     int             // number of blocks listed in blocks.csv
+	BlockTypes map[string]int  // number of blocks per type ("lead", "data")
+	Duration   float64         // total duration of the listed blocks in seconds
+}
+
+// InspectCPK reads the manifest and blocks.csv of a .cpk package and lists its files,
+// streaming the archive without reading the block wavs (see CPKReader).
+func InspectCPK(cpkPath string) (CPKInfo, error) {
+	reader, err := OpenCPK(cpkPath)
+	if err != nil {
+		return CPKInfo{}, err
+	}
+	defer reader.Close()
+
+	var info CPKInfo
+	var manifestData, csvData []byte
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CPKInfo{}, err
+		}
+		info.Members = append(info.Members, CPKMemberInfo{Name: header.Name, Size: header.Size})
+
+		switch header.Name {
+		case "package_manifest.json":
+			manifestData, err = io.ReadAll(reader)
+		case "blocks.csv":
+			csvData, err = io.ReadAll(reader)
+		}
+		if err != nil {
+			return CPKInfo{}, err
+		}
+	}
+
+	if manifestData == nil {
+		return CPKInfo{}, errors.New("package_manifest.json missing")
+	}
+	if err := json.Unmarshal(manifestData, &info.Manifest); err != nil {
+		return CPKInfo{}, fmt.Errorf("invalid package_manifest.json: %w", err)
+	}
+	if csvData == nil {
+		return CPKInfo{}, errors.New("blocks.csv missing")
+	}
+	rows, err := _parseBlocksCSV(csvData)
+	if err != nil {
+		return CPKInfo{}, err
+	}
+
+	info.Blocks = len(rows)
+	info.BlockTypes = make(map[string]int)
+	for _, row := range rows {
+		info.BlockTypes[row.blockType]++
+		info.Duration += row.endTime - row.startTime
+	}
+	return info, nil
+}
@@ -0,0 +1,149 @@
+// internal/export/playlist.go
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"os"
+	"strings"
+)
+
+// cueMaxTracks is the maximum number of tracks a cue sheet may hold (red book limit).
+const cueMaxTracks = 99
+
+// ExportPlaylist generates an extended .m3u playlist listing the block .wav files in
+// the same order and with the same names as the .cpk archive, using the idx tag (if any)
+// as title. It uses the _getGroupedBlockInfo helper to identify blocks.
+//
+// if an outputPath is provided, the generated playlist is also written to that file path.
+func ExportPlaylist(indexData []audio.IndexEntry, outputPath string, sampleRate float64, policy GroupPolicy) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %f", sampleRate)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("#EXTM3U\n")
+
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy)
+
+		if groupInfo.IsBlock {
+			wavFileName := fmt.Sprintf("block_%03d_%s.wav", blockCount, groupInfo.BlockType)
+			duration := int(groupInfo.BlockEndTime - groupInfo.StartEntry.StartTime + 0.5) // whole seconds, rounded
+			fmt.Fprintf(buf, "#EXTINF:%d,%s\n%s\n", duration, _playlistTitle(groupInfo, blockCount), wavFileName)
+			blockCount++
+		}
+		i += groupInfo.ConsumedEntries
+	}
+
+	if outputPath != "" {
+		if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("error writing playlist file %s: %w", outputPath, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ExportCueSheet generates a .cue sheet for a single audio file (audioFileName) with one
+// track per block, starting at the block's start time and titled with its idx tag (if any).
+// cue sheets are limited to 99 tracks; further blocks are left out with a warning.
+//
+// if an outputPath is provided, the generated cue sheet is also written to that file path.
+func ExportCueSheet(indexData []audio.IndexEntry, audioFileName string, outputPath string, sampleRate float64, policy GroupPolicy) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %f", sampleRate)
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "FILE \"%s\" WAVE\n", _cueQuote(audioFileName))
+
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy)
+
+		if groupInfo.IsBlock {
+			if blockCount == cueMaxTracks {
+				fmt.Printf("warning: cue sheet limited to %d tracks, remaining blocks left out.\n", cueMaxTracks)
+				break
+			}
+			// cue time is minutes:seconds:frames with 75 frames per second
+			frames := int(groupInfo.StartEntry.StartTime * 75)
+			fmt.Fprintf(buf, "  TRACK %02d AUDIO\n", blockCount+1)
+			fmt.Fprintf(buf, "    TITLE \"%s\"\n", _cueQuote(_playlistTitle(groupInfo, blockCount)))
+			fmt.Fprintf(buf, "    INDEX 01 %02d:%02d:%02d\n", frames/(75*60), (frames/75)%60, frames%75)
+			blockCount++
+		}
+		i += groupInfo.ConsumedEntries
+	}
+
+	if outputPath != "" {
+		if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("error writing cue file %s: %w", outputPath, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ExportChapters generates an ffmpeg metadata file (FFMETADATA1) with one chapter per block,
+// spanning the block's start to end time (in milliseconds) and titled with its idx tag (if any).
+// it can be muxed with the audio into a container that supports chapters (e.g. matroska), so
+// media players can jump from block to block.
+//
+// if an outputPath is provided, the generated chapter file is also written to that file path.
+func ExportChapters(indexData []audio.IndexEntry, outputPath string, sampleRate float64, policy GroupPolicy) ([]byte, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate: %f", sampleRate)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(";FFMETADATA1\n")
+
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy)
+
+		if groupInfo.IsBlock {
+			start := int64(groupInfo.StartEntry.StartTime*1000 + 0.5) // milliseconds, rounded
+			end := int64(groupInfo.BlockEndTime*1000 + 0.5)
+			fmt.Fprintf(buf, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+				start, end, _ffmetadataEscape(_playlistTitle(groupInfo, blockCount)))
+			blockCount++
+		}
+		i += groupInfo.ConsumedEntries
+	}
+
+	if outputPath != "" {
+		if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("error writing chapter file %s: %w", outputPath, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// _playlistTitle returns the idx tag of a block, or a generic title if it has none.
+func _playlistTitle(groupInfo _groupedBlockInfo, blockCount int) string {
+	title := strings.TrimSpace(strings.ReplaceAll(groupInfo.StartEntry.IDXTag, "\n", " "))
+	if title == "" {
+		title = fmt.Sprintf("block %03d (%s)", blockCount, groupInfo.BlockType)
+	}
+	return title
+}
+
+// _cueQuote makes s safe to use within a double-quoted cue sheet field.
+func _cueQuote(s string) string {
+	return strings.ReplaceAll(s, "\"", "'")
+}
+
+// _ffmetadataEscape escapes the characters with a special meaning in ffmpeg metadata values.
+func _ffmetadataEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#").Replace(s)
+}
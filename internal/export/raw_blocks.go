@@ -0,0 +1,64 @@
+// internal/export/raw_blocks.go
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// DumpRawBlocks writes the original tap pulse bytes of every grouped block to outDir as
+// block_NNN_type.bin, using the same block numbering and grouping as the .cpk archive, plus
+// an index.csv mapping each file to its byte range in the .tap file (end inclusive).
+// this is meant for studying unknown loaders in a hex editor. returns the number of blocks.
+func DumpRawBlocks(tapData []byte, indexData []audio.IndexEntry, outDir string, sampleRate float64, policy GroupPolicy) (int, error) {
+	if sampleRate <= 0 {
+		return 0, fmt.Errorf("invalid sample rate: %f", sampleRate)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, fmt.Errorf("error creating output directory %s: %w", outDir, err)
+	}
+
+	indexBuffer := new(bytes.Buffer)
+	w := tabwriter.NewWriter(indexBuffer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "file\t|\thex_start_position\t|\thex_end_position\t|\tbytes\t")
+
+	blockCount := 0
+	i := 0
+	for i < len(indexData) {
+		groupInfo := _getGroupedBlockInfo(indexData, i, sampleRate, policy)
+
+		if groupInfo.IsBlock {
+			binFileName := fmt.Sprintf("block_%03d_%s.bin", blockCount, groupInfo.BlockType)
+			// byte range of the block including its trailing pause (if grouped), capped to the tap data
+			startPos := groupInfo.StartEntry.StartPosition
+			endPos := max(groupInfo.EndEntry.EndPosition, groupInfo.StartEntry.EndPosition) + 1 // exclusive
+			endPos = min(endPos, len(tapData))
+			if startPos < 0 || startPos >= endPos {
+				fmt.Printf("warning: block %d has invalid byte range 0x%x-0x%x, skipping %s.\n", blockCount, startPos, endPos, binFileName)
+			} else {
+				binPath := filepath.Join(outDir, binFileName)
+				if err := os.WriteFile(binPath, tapData[startPos:endPos], 0644); err != nil {
+					return blockCount, fmt.Errorf("error writing raw block file %s: %w", binPath, err)
+				}
+				fmt.Fprintf(w, "%s\t|\t0x%08x\t|\t0x%08x\t|\t%d\t\n", binFileName, startPos, endPos-1, endPos-startPos)
+			}
+			blockCount++ // numbering follows the cpk blocks, also for skipped ones
+		}
+		i += groupInfo.ConsumedEntries
+	}
+
+	if err := w.Flush(); err != nil {
+		return blockCount, fmt.Errorf("error flushing tabwriter: %w", err)
+	}
+	indexPath := filepath.Join(outDir, "index.csv")
+	if err := os.WriteFile(indexPath, indexBuffer.Bytes(), 0644); err != nil {
+		return blockCount, fmt.Errorf("error writing raw block index %s: %w", indexPath, err)
+	}
+
+	return blockCount, nil
+}
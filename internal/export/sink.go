@@ -0,0 +1,96 @@
+// internal/export/sink.go
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// OutputSink creates the named outputs (files) written by the packager and audio writers.
+// a library user can supply an in-memory or network sink; the cli uses FileSink.
+type OutputSink interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+// FileSink is the default OutputSink, creating (or replacing) files on the local filesystem.
+// each file is written to a temp file first and only moved into place once complete, so a
+// failed conversion never leaves a truncated output behind.
+type FileSink struct {
+	Temp TempPolicy // where temp files are staged and whether failed ones are kept
+}
+
+// Create starts writing the file at path name; closing the writer moves it into place.
+func (s FileSink) Create(name string) (io.WriteCloser, error) {
+	return CreateTemp(s.Temp, name)
+}
+
+// WriteOutput creates the output name in sink, lets write fill it and closes it, returning
+// the first error encountered. if write fails, an output that can be aborted (such as the
+// temp files of FileSink) is discarded instead. a nil sink writes to the filesystem.
+func WriteOutput(sink OutputSink, name string, write func(w io.Writer) error) (err error) {
+	sink = _sinkOrDefault(sink)
+	out, err := sink.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating output %s: %w", name, err)
+	}
+	defer func() {
+		if closeErr := _closeOrAbort(out, err != nil); err == nil && closeErr != nil {
+			err = fmt.Errorf("error closing output %s: %w", name, closeErr)
+		}
+	}()
+
+	return write(out)
+}
+
+// _sinkOrDefault returns sink, or a FileSink if sink is nil.
+func _sinkOrDefault(sink OutputSink) OutputSink {
+	if sink == nil {
+		return FileSink{}
+	}
+	return sink
+}
+
+// ContextSink wraps sink so that writes fail with the context's error once ctx is done.
+// combined with FileSink, an output interrupted this way is discarded instead of left truncated.
+func ContextSink(ctx context.Context, sink OutputSink) OutputSink {
+	return _contextSink{ctx: ctx, sink: _sinkOrDefault(sink)}
+}
+
+type _contextSink struct {
+	ctx  context.Context
+	sink OutputSink
+}
+
+func (s _contextSink) Create(name string) (io.WriteCloser, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	out, err := s.sink.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &_contextWriter{ctx: s.ctx, out: out}, nil
+}
+
+// _contextWriter checks the context before every write and passes aborts on to the output.
+type _contextWriter struct {
+	ctx context.Context
+	out io.WriteCloser
+}
+
+func (w *_contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.out.Write(p)
+}
+
+func (w *_contextWriter) Close() error {
+	return w.out.Close()
+}
+
+func (w *_contextWriter) Abort() error {
+	return _closeOrAbort(w.out, true)
+}
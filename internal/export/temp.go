@@ -0,0 +1,139 @@
+// internal/export/temp.go
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TempPolicy controls how outputs are staged on disk before they are moved into place.
+// the zero value stages each output in the directory of its target and removes it on failure.
+type TempPolicy struct {
+	Dir  string // directory for temp files ("" = directory of the target file)
+	Keep bool   // keep temp files of failed outputs for debugging instead of removing them
+}
+
+// TempFile is an output being written to a temp file. Commit moves it to its target,
+// Abort discards it (unless the policy keeps temp files). both close the file and are
+// safe to call more than once; only the first call has an effect.
+type TempFile struct {
+	*os.File
+	target string
+	policy TempPolicy
+	done   bool
+}
+
+// CreateTemp creates a temp file for the output target according to policy.
+func CreateTemp(policy TempPolicy, target string) (*TempFile, error) {
+	dir := policy.Dir
+	if dir == "" {
+		dir = filepath.Dir(target)
+	}
+	file, err := os.CreateTemp(dir, filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp file for %s: %w", target, err)
+	}
+	return &TempFile{File: file, target: target, policy: policy}, nil
+}
+
+// Close commits the temp file, so a TempFile can be used wherever an io.WriteCloser is expected.
+func (t *TempFile) Close() error {
+	return t.Commit()
+}
+
+// Commit closes the temp file and moves it to its target, replacing an existing file.
+func (t *TempFile) Commit() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	// temp files are created private (0600), outputs get the usual file mode
+	err := t.File.Chmod(0644)
+	if closeErr := t.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = _moveFile(t.File.Name(), t.target)
+	}
+	if err != nil {
+		t.discard()
+		return fmt.Errorf("error moving temp file into place as %s: %w", t.target, err)
+	}
+	return nil
+}
+
+// Abort closes the temp file and removes it, or keeps it under the policy's Keep setting.
+func (t *TempFile) Abort() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	t.File.Close()
+	t.discard()
+	return nil
+}
+
+// discard removes the (closed) temp file unless the policy keeps temp files.
+func (t *TempFile) discard() {
+	if t.policy.Keep {
+		fmt.Printf("keeping temp file of failed output %s: %s\n", t.target, t.File.Name())
+		return
+	}
+	if err := os.Remove(t.File.Name()); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("warning: error removing temp file %s: %v\n", t.File.Name(), err)
+	}
+}
+
+// _aborter is implemented by outputs that can be discarded instead of closed after a failure.
+type _aborter interface {
+	Abort() error
+}
+
+// _closeOrAbort closes out, or aborts it if failed and out supports aborting.
+func _closeOrAbort(out io.Closer, failed bool) error {
+	if a, ok := out.(_aborter); ok && failed {
+		return a.Abort()
+	}
+	return out.Close()
+}
+
+// _moveFile renames src to dst, falling back to copying src into a temp file next to dst
+// and renaming that over dst if both are on different filesystems (e.g. with a temp dir on
+// another mount). an existing dst is only replaced once the copy is complete.
+func _moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Chmod(0644)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Remove(src)
+}
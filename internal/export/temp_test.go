@@ -0,0 +1,51 @@
+// internal/export/temp_test.go
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveFileKeepsTargetOnFailedCopy(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "out.wav")
+	if err := os.WriteFile(dst, []byte("previous output"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a directory cannot be renamed over a file, and reading it for the copy fails as well
+	src := filepath.Join(dir, "staged")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := _moveFile(src, dst); err == nil {
+		t.Fatal("no error moving a directory over a file")
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "previous output" {
+		t.Errorf("target now holds %q (error %v), want the previous output", data, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "out.wav.*.tmp")); len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "staged"), filepath.Join(dir, "out.wav")
+	for _, file := range []string{src, dst} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := _moveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != src {
+		t.Errorf("target holds %q (error %v), want the moved file", data, err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists (stat error %v)", err)
+	}
+}
@@ -0,0 +1,245 @@
+// internal/export/verify.go
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// VerifyCPK checks the internal consistency of a .cpk package: the manifest must be present
+// and valid, every block listed in blocks.csv must be present as a valid .wav file matching the
+// manifest's format with the sample count implied by its start and end time (+/- one sample,
+// plus the manifest's block lead-in),
+// and if the package holds a checksums.txt, every listed file must match its sha256.
+// the package is streamed, only the text members are kept in memory.
+// it returns the number of verified blocks, or an error describing the first discrepancy.
+func VerifyCPK(cpkPath string) (int, error) {
+	members, err := _scanCPK(cpkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// manifest
+	manifestMember, ok := members["package_manifest.json"]
+	if !ok {
+		return 0, errors.New("package_manifest.json missing")
+	}
+	var manifest PackageManifest
+	if err := json.Unmarshal(manifestMember.data, &manifest); err != nil {
+		return 0, fmt.Errorf("invalid package_manifest.json: %w", err)
+	}
+	if manifest.SampleRate <= 0 {
+		return 0, fmt.Errorf("invalid sample rate in manifest: %d", manifest.SampleRate)
+	}
+
+	// blocks listed in the csv
+	csvMember, ok := members["blocks.csv"]
+	if !ok {
+		return 0, errors.New("blocks.csv missing")
+	}
+	rows, err := _parseBlocksCSV(csvMember.data)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		wav, ok := members[row.file]
+		if !ok {
+			return 0, fmt.Errorf("%s listed in blocks.csv but missing", row.file)
+		}
+		if wav.wavErr != nil {
+			return 0, fmt.Errorf("%s: %w", row.file, wav.wavErr)
+		}
+		if wav.sampleRate != manifest.SampleRate || wav.bitsPerSample != manifest.AudioBitsPerSample || wav.channels != manifest.AudioChannels {
+			return 0, fmt.Errorf("%s: format %d hz/%d-bit/%d ch does not match manifest %d hz/%d-bit/%d ch", row.file,
+				wav.sampleRate, wav.bitsPerSample, wav.channels, manifest.SampleRate, manifest.AudioBitsPerSample, manifest.AudioChannels)
+		}
+		samples := wav.dataSize/(wav.bitsPerSample/8*wav.channels) - manifest.BlockLeadInSamples
+		expected := int(math.Round((row.endTime - row.startTime) * float64(wav.sampleRate)))
+		if samples < expected-1 || samples > expected+1 {
+			return 0, fmt.Errorf("%s: holds %d samples, blocks.csv implies %d", row.file, samples, expected)
+		}
+	}
+
+	// optional per-block checksums
+	if checksums, ok := members["checksums.txt"]; ok {
+		if err := _verifyChecksums(checksums.data, members); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+// _cpkMember summarises a member of a .cpk package as scanned by _scanCPK.
+type _cpkMember struct {
+	data          []byte // content of text members; nil for .wav members
+	sha256        string // hex sha256 of the content
+	sampleRate    int    // wav format (.wav members only)
+	bitsPerSample int
+	channels      int
+	dataSize      int
+	wavErr        error // invalid wav header or size (.wav members only)
+}
+
+// _scanCPK streams all members of a .cpk package, keyed by name. the content of .wav members
+// is only hashed and its header checked, not kept.
+func _scanCPK(cpkPath string) (map[string]_cpkMember, error) {
+	reader, err := OpenCPK(cpkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	members := make(map[string]_cpkMember)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var member _cpkMember
+		hash := sha256.New()
+		content := io.TeeReader(reader, hash)
+		if strings.HasSuffix(header.Name, ".wav") {
+			member.sampleRate, member.bitsPerSample, member.channels, member.dataSize, member.wavErr = _scanWAV(content)
+			_, err = io.Copy(io.Discard, content) // rest of an invalid wav, for the hash
+		} else {
+			member.data, err = io.ReadAll(content)
+		}
+		if err != nil {
+			return nil, err
+		}
+		member.sha256 = fmt.Sprintf("%x", hash.Sum(nil))
+		members[header.Name] = member
+	}
+	return members, nil
+}
+
+// _blocksCSVRow holds the fields of a blocks.csv row.
+type _blocksCSVRow struct {
+	startTime float64
+	endTime   float64
+	blockType string
+	idxTag    string
+	hexStart  string
+	file      string
+}
+
+// _parseBlocksCSV parses the '|' separated table written by ExportBlockInfo.
+func _parseBlocksCSV(csvData []byte) ([]_blocksCSVRow, error) {
+	var rows []_blocksCSVRow
+	scanner := bufio.NewScanner(bytes.NewReader(csvData))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if lineNumber == 1 || strings.TrimSpace(scanner.Text()) == "" {
+			continue // header or empty line
+		}
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("blocks.csv line %d: expected 6 columns, found %d", lineNumber, len(fields))
+		}
+		startTime, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("blocks.csv line %d: invalid start time: %w", lineNumber, err)
+		}
+		endTime, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("blocks.csv line %d: invalid end time: %w", lineNumber, err)
+		}
+		rows = append(rows, _blocksCSVRow{
+			startTime: startTime,
+			endTime:   endTime,
+			blockType: strings.TrimSpace(fields[2]),
+			idxTag:    strings.TrimSpace(fields[3]),
+			hexStart:  strings.TrimSpace(fields[4]),
+			file:      strings.TrimSpace(fields[len(fields)-1]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading blocks.csv: %w", err)
+	}
+	return rows, nil
+}
+
+// _verifyChecksums checks every "<sha256>  <file>" line of checksums.txt against members.
+func _verifyChecksums(checksums []byte, members map[string]_cpkMember) error {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return fmt.Errorf("invalid checksums.txt line: %s", line)
+		}
+		member, ok := members[name]
+		if !ok {
+			return fmt.Errorf("%s listed in checksums.txt but missing", name)
+		}
+		if member.sha256 != hash {
+			return fmt.Errorf("%s: sha256 %s does not match checksums.txt %s", name, member.sha256, hash)
+		}
+	}
+	return scanner.Err()
+}
+
+// _readCPKFiles reads all files of a .cpk package into memory, keyed by name.
+func _readCPKFiles(cpkPath string) (map[string][]byte, error) {
+	reader, err := OpenCPK(cpkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	files := make(map[string][]byte)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = data
+	}
+	return files, nil
+}
+
+// _scanWAV parses the wav header from r using audio.ParseWAVHeader and reads the sample data,
+// checking that the data size matches the content and the format is supported.
+func _scanWAV(r io.Reader) (sampleRate, bitsPerSample, channels, dataSize int, err error) {
+	sampleRate, bitsPerSample, channels, dataSize, err = audio.ParseWAVHeader(r)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	contentSize, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if int64(dataSize) != contentSize {
+		return 0, 0, 0, 0, fmt.Errorf("wav data size %d does not match file content of %d bytes", dataSize, contentSize)
+	}
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return 0, 0, 0, 0, fmt.Errorf("unsupported wav format: %d bits", bitsPerSample)
+	}
+	return sampleRate, bitsPerSample, channels, dataSize, nil
+}
@@ -0,0 +1,232 @@
+// internal/export/volumes.go
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// VolumeIndex describes how a .cpk archive was split into size-limited volumes.
+// it is written next to the volumes (<name>.cpk.volumes.json) and used to reassemble them.
+type VolumeIndex struct {
+	Archive   string        `json:"archive"`    // base name of the original .cpk archive
+	TotalSize int64         `json:"total_size"` // size of the reassembled archive in bytes
+	Volumes   []VolumeEntry `json:"volumes"`    // volumes in reassembly order
+}
+
+// VolumeEntry holds the file name and size of a single volume.
+type VolumeEntry struct {
+	File string `json:"file"` // base name of the volume file (e.g. game.cpk.001)
+	Size int64  `json:"size"` // size of the volume in bytes
+}
+
+// _boundaryWriter is implemented by writers that split the stream they receive, so they can
+// do so at the points marked by Boundary (between the block .wav files of an archive).
+type _boundaryWriter interface {
+	io.Writer
+	Boundary() error
+}
+
+// _volumeWriter is an io.WriteCloser that spreads the archive stream over numbered volume
+// files (<outPath>.001, .002, ...) of at most limit bytes. the stream is held back up to the
+// next Boundary and rolls over to a new volume before a part that would exceed the limit, so
+// volumes end at block boundaries; only a part larger than the limit is split across volumes.
+// concatenating the volumes in order yields the original archive.
+type _volumeWriter struct {
+	sink        OutputSink
+	outPath     string
+	limit       int64
+	current     io.WriteCloser
+	currentName string
+	written     int64        // bytes written to the current volume
+	pending     bytes.Buffer // bytes written since the last boundary
+	index       VolumeIndex
+}
+
+// _newVolumeWriter creates a volume writer for outPath with the given per-volume size limit,
+// creating the volumes and the volume index in sink.
+func _newVolumeWriter(sink OutputSink, outPath string, limit int64) (*_volumeWriter, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid volume size limit: %d", limit)
+	}
+	return &_volumeWriter{sink: sink, outPath: outPath, limit: limit, index: VolumeIndex{Archive: filepath.Base(outPath)}}, nil
+}
+
+// Write adds p to the part of the stream up to the next boundary.
+func (v *_volumeWriter) Write(p []byte) (int, error) {
+	return v.pending.Write(p)
+}
+
+// Boundary marks the end of a part of the stream (e.g. a block .wav file) and writes the part,
+// starting a new volume first if it does not fit into the current one.
+func (v *_volumeWriter) Boundary() error {
+	if v.pending.Len() == 0 {
+		return nil
+	}
+	if v.current != nil && v.written > 0 && v.written+int64(v.pending.Len()) > v.limit {
+		if err := v.nextVolume(); err != nil {
+			return err
+		}
+	}
+	_, err := v.writeVolumes(v.pending.Bytes())
+	v.pending.Reset()
+	return err
+}
+
+// writeVolumes writes p across as many volumes as needed, never exceeding the size limit per volume.
+func (v *_volumeWriter) writeVolumes(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		// open the next volume if none is open yet or the current one is full
+		if v.current == nil || v.written >= v.limit {
+			if err := v.nextVolume(); err != nil {
+				return total, err
+			}
+		}
+
+		chunk := p
+		if remaining := v.limit - v.written; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		n, err := v.current.Write(chunk)
+		total += n
+		v.written += int64(n)
+		v.index.TotalSize += int64(n)
+		v.index.Volumes[len(v.index.Volumes)-1].Size += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// nextVolume closes the current volume (if any) and creates the next numbered one.
+func (v *_volumeWriter) nextVolume() error {
+	if v.current != nil {
+		if err := v.current.Close(); err != nil {
+			return fmt.Errorf("error closing volume %s: %w", v.currentName, err)
+		}
+	}
+
+	volumePath := fmt.Sprintf("%s.%03d", v.outPath, len(v.index.Volumes)+1)
+	file, err := v.sink.Create(volumePath)
+	if err != nil {
+		return fmt.Errorf("error creating volume %s: %w", volumePath, err)
+	}
+	v.current = file
+	v.currentName = volumePath
+	v.written = 0
+	v.index.Volumes = append(v.index.Volumes, VolumeEntry{File: filepath.Base(volumePath)})
+	return nil
+}
+
+// Close writes the rest of the stream, closes the last volume and writes the volume index
+// next to the volumes.
+func (v *_volumeWriter) Close() error {
+	if err := v.Boundary(); err != nil {
+		return err
+	}
+	if v.current != nil {
+		if err := v.current.Close(); err != nil {
+			return fmt.Errorf("error closing volume %s: %w", v.currentName, err)
+		}
+		v.current = nil
+	}
+
+	indexData, err := json.MarshalIndent(v.index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling volume index to json: %w", err)
+	}
+	indexPath := v.outPath + ".volumes.json"
+	err = WriteOutput(v.sink, indexPath, func(w io.Writer) error {
+		_, err := w.Write(indexData)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error writing volume index %s: %w", indexPath, err)
+	}
+	return nil
+}
+
+// Abort discards the current volume (if it supports aborting) and skips the volume index,
+// so a failed archive does not look complete. volumes already finished are left as they are.
+func (v *_volumeWriter) Abort() error {
+	v.pending.Reset()
+	if v.current != nil {
+		err := _closeOrAbort(v.current, true)
+		v.current = nil
+		return err
+	}
+	return nil
+}
+
+// JoinCPKVolumes reassembles a split .cpk archive from its volume index file
+// (<name>.cpk.volumes.json). the volumes are expected next to the index file and
+// their sizes are checked against the index. the archive is written next to the
+// index as well, staged as a temp file according to temp; its path is returned on success.
+func JoinCPKVolumes(indexPath string, temp TempPolicy) (outPath string, err error) {
+	indexData, err := os.ReadFile(indexPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading volume index %s: %w", indexPath, err)
+	}
+	var index VolumeIndex
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return "", fmt.Errorf("error parsing volume index %s: %w", indexPath, err)
+	}
+	if index.Archive == "" || len(index.Volumes) == 0 {
+		return "", fmt.Errorf("invalid volume index %s: no archive name or volumes", indexPath)
+	}
+
+	dir := filepath.Dir(indexPath)
+	outPath = filepath.Join(dir, filepath.Base(index.Archive))
+	out, err := CreateTemp(temp, outPath)
+	if err != nil {
+		return "", fmt.Errorf("error creating output file %s: %w", outPath, err)
+	}
+	defer func() {
+		closeErr := _closeOrAbort(out, err != nil)
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("error closing output file %s: %w", outPath, closeErr)
+		}
+	}()
+
+	// append each volume in order, verifying its size against the index
+	var total int64
+	for _, volume := range index.Volumes {
+		volumePath := filepath.Join(dir, filepath.Base(volume.File))
+		n, err := _appendFile(out, volumePath)
+		if err != nil {
+			return "", err
+		}
+		if n != volume.Size {
+			return "", fmt.Errorf("volume %s has %d bytes, index declares %d", volumePath, n, volume.Size)
+		}
+		total += n
+	}
+	if total != index.TotalSize {
+		return "", fmt.Errorf("reassembled archive has %d bytes, index declares %d", total, index.TotalSize)
+	}
+
+	return outPath, nil
+}
+
+// _appendFile copies the content of the file at path to w and returns the number of bytes copied.
+func _appendFile(w io.Writer, path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening volume %s: %w", path, err)
+	}
+	defer file.Close()
+
+	n, err := io.Copy(w, file)
+	if err != nil {
+		return n, fmt.Errorf("error copying volume %s: %w", path, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,105 @@
+// internal/export/volumes_test.go
+
+package export
+
+import (
+	"bytes"
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/testutil"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// _memorySink is an OutputSink keeping every created output in memory, in creation order.
+type _memorySink struct {
+	names []string
+	files map[string]*bytes.Buffer
+}
+
+func (s *_memorySink) Create(name string) (io.WriteCloser, error) {
+	if s.files == nil {
+		s.files = make(map[string]*bytes.Buffer)
+	}
+	s.names = append(s.names, name)
+	s.files[name] = new(bytes.Buffer)
+	return _nopCloser{s.files[name]}, nil
+}
+
+func TestVolumeWriterSplitsAtBoundaries(t *testing.T) {
+	sink := &_memorySink{}
+	v, err := _newVolumeWriter(sink, "test.cpk", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// parts of 4 bytes fit 2 per volume; a 25 byte part starts a new volume and is split over 3
+	var stream []byte
+	for _, part := range [][]byte{bytes.Repeat([]byte{1}, 4), bytes.Repeat([]byte{2}, 4), bytes.Repeat([]byte{3}, 4), bytes.Repeat([]byte{4}, 25), {5}} {
+		stream = append(stream, part...)
+		if _, err := v.Write(part); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Boundary(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSizes := []int{8, 4, 10, 10, 6}
+	var joined []byte
+	for i, volume := range v.index.Volumes {
+		data := sink.files[volume.File].Bytes()
+		if i >= len(wantSizes) || len(data) != wantSizes[i] || int64(len(data)) != volume.Size {
+			t.Errorf("volume %s: %d bytes (index %d), want sizes %v", volume.File, len(data), volume.Size, wantSizes)
+		}
+		joined = append(joined, data...)
+	}
+	if len(v.index.Volumes) != len(wantSizes) {
+		t.Errorf("got %d volumes, want %d", len(v.index.Volumes), len(wantSizes))
+	}
+	// the 4 byte part 3 starts the second volume instead of being split
+	if len(v.index.Volumes) > 1 && sink.files[v.index.Volumes[1].File].Bytes()[0] != 3 {
+		t.Errorf("second volume does not start with the third part")
+	}
+	if !bytes.Equal(joined, stream) {
+		t.Errorf("joined volumes differ from the written stream")
+	}
+}
+
+func TestSplitPackageJoinsToValidArchive(t *testing.T) {
+	testutil.Quiet(t)
+	pcm, indexData := _processTestTAP(t, 2, 3000, audio.ProcessOptions{})
+	dir := t.TempDir()
+	base := filepath.Join(dir, "split")
+
+	const limit = 2048
+	blocks, err := SplitAndPackageBlocks(pcm, indexData, base, constants.SampleRate, constants.ClockPAL, "c64", PackageOptions{SplitSize: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outPath, err := JoinCPKVolumes(base+".cpk.volumes.json", TempPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := VerifyCPK(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified != blocks {
+		t.Errorf("verified %d blocks, packaged %d", verified, blocks)
+	}
+
+	volumes, _ := filepath.Glob(base + ".cpk.0*")
+	if len(volumes) < 2 {
+		t.Fatalf("got %d volumes, want the package split", len(volumes))
+	}
+	for _, volume := range volumes {
+		if info, err := os.Stat(volume); err != nil || info.Size() > limit {
+			t.Errorf("volume %s exceeds the %d byte limit", volume, limit)
+		}
+	}
+}
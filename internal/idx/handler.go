@@ -13,11 +13,16 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
 	idxPositionBase = 16 // hexadecimal position
 	idxPositionBits = 32 // assuming positions fit within 32 bits
+
+	// idxOffsetDirective starts a comment line setting a base offset (decimal, or hex with 0x
+	// prefix, may be negative) that is added to the positions of all following lines.
+	idxOffsetDirective = ";offset "
 )
 
 // IDXEntry holds data parsed from one line of a .idx file.
@@ -31,9 +36,12 @@ type IDXEntry struct {
 }
 
 // ReadIDX opens and parses a tape index (.idx) file specified by filepath.
-// it expects lines in the format "<HexPosition> <Name>", allowing an optional "0x"
-// prefix for the position. comment lines starting with ';' and empty lines are
-// skipped. returns a slice of IDXEntry structs containing the parsed positions and
+// it expects lines in the format "<HexPosition> <Name>", separated by spaces or tabs and
+// allowing an optional "0x" prefix for the position. comment lines starting with ';' and empty lines are
+// skipped, except for the ";offset <n>" directive which adds n to the positions of
+// all following lines (e.g. ";offset 20" for files omitting the .tap header). if several lines share the same position, the last one wins (a warning
+// is printed) and the entry keeps the place of the first occurrence.
+// returns a slice of IDXEntry structs containing the parsed positions and
 // names - or an error if opening or parsing fails.
 func ReadIDX(filepath string) ([]IDXEntry, error) {
 	file, err := os.Open(filepath)
@@ -42,7 +50,9 @@ func ReadIDX(filepath string) ([]IDXEntry, error) {
 	}
 	defer file.Close()
 
-	var entries []IDXEntry // slice to hold results
+	var entries []IDXEntry             // slice to hold results
+	positionIndex := make(map[int]int) // position -> index in entries, to detect duplicates
+	baseOffset := 0                    // set by the offset directive
 	scanner := bufio.NewScanner(file)
 	lineNumber := 0
 
@@ -51,17 +61,30 @@ func ReadIDX(filepath string) ([]IDXEntry, error) {
 		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
 
+		// offset directive applies to all following positions
+		if strings.HasPrefix(strings.ToLower(line), idxOffsetDirective) {
+			offsetStr := strings.TrimSpace(line[len(idxOffsetDirective):])
+			offset, err := strconv.ParseInt(offsetStr, 0, idxPositionBits)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid offset directive '%s': %w", lineNumber, offsetStr, err)
+			}
+			baseOffset = int(offset)
+			continue
+		}
+
 		// skip empty lines and comments
 		if line == "" || strings.HasPrefix(line, ";") {
 			continue
 		}
 
-		// expect format "<position> <name>", split at first space only
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) != 2 {
+		// expect format "<position> <name>", split at the first run of spaces or tabs only
+		// (the name keeps its internal spaces)
+		separator := strings.IndexFunc(line, unicode.IsSpace)
+		if separator < 0 {
 			// return error indicating format issue and line number
 			return nil, fmt.Errorf("line %d: invalid idx line format: %s", lineNumber, line)
 		}
+		parts := []string{line[:separator], line[separator:]}
 
 		// parse the position part (hexadecimal)
 		positionStr := strings.TrimPrefix(parts[0], "0x") // allow optional "0x" prefix
@@ -70,10 +93,20 @@ func ReadIDX(filepath string) ([]IDXEntry, error) {
 			return nil, fmt.Errorf("line %d: invalid hex position '%s': %w", lineNumber, parts[0], err)
 		}
 
+		position += int64(baseOffset)
+
 		// parse the name part (trim extra space)
 		name := strings.TrimSpace(parts[1])
 
+		// duplicate position: last one wins, keeping the order of the first occurrence
+		if existing, ok := positionIndex[int(position)]; ok {
+			fmt.Printf("warning: line %d: duplicate idx position 0x%x, replacing '%s' with '%s'.\n", lineNumber, position, entries[existing].Name, name)
+			entries[existing].Name = name
+			continue
+		}
+
 		// append valid entry to the slice
+		positionIndex[int(position)] = len(entries)
 		entries = append(entries, IDXEntry{Position: int(position), Name: name})
 	}
 
@@ -85,3 +118,14 @@ func ReadIDX(filepath string) ([]IDXEntry, error) {
 	// return successfully parsed entries
 	return entries, nil
 }
+
+// ShiftPositions returns a copy of entries with offset added to every Position.
+// this is used to convert between idx files that include the .tap header in their
+// positions and those that do not (offset +/- constants.TapHeaderSize).
+func ShiftPositions(entries []IDXEntry, offset int) []IDXEntry {
+	shifted := make([]IDXEntry, len(entries))
+	for i, entry := range entries {
+		shifted[i] = IDXEntry{Position: entry.Position + offset, Name: entry.Name}
+	}
+	return shifted
+}
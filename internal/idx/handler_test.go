@@ -0,0 +1,57 @@
+// internal/idx/handler_test.go
+
+package idx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// _writeIDX writes content to a temp .idx file and returns its path.
+func _writeIDX(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.idx")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadIDXDuplicatePositions(t *testing.T) {
+	// the last name of a duplicate position wins, at the place of the first occurrence
+	path := _writeIDX(t, "0x14 FIRST\n0x2000 OTHER\n0x14 SECOND\n0x2000 LAST\n0x3000 END\n")
+	entries, err := ReadIDX(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IDXEntry{{0x14, "SECOND"}, {0x2000, "LAST"}, {0x3000, "END"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v, want %v", entries, want)
+	}
+}
+
+func TestReadIDXSeparators(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []IDXEntry
+	}{
+		{"single space", "0x14 GAME LOADER\n", []IDXEntry{{0x14, "GAME LOADER"}}},
+		{"tab", "0x14\tGAME LOADER\n0x2000\tLEVEL 2\n", []IDXEntry{{0x14, "GAME LOADER"}, {0x2000, "LEVEL 2"}}},
+		{"multiple spaces", "0x14    GAME  LOADER\n2000   LEVEL 2\n", []IDXEntry{{0x14, "GAME  LOADER"}, {0x2000, "LEVEL 2"}}},
+		{"mixed tabs and spaces", "0x14 \t GAME LOADER \t\n", []IDXEntry{{0x14, "GAME LOADER"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadIDX(_writeIDX(t, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entries, tt.want) {
+				t.Errorf("got %q, want %q", entries, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// internal/options/options.go
+
+// package options provides the canonical default conversion settings shared by the
+// command-line tool and the mobile api, so both entry points start from the same values
+// and only differ in the overrides they apply.
+package options
+
+import (
+	"fmt"
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/export"
+	"strings"
+)
+
+// Options holds the user-facing conversion settings.
+type Options struct {
+	ClockType    string // clock standard: "pal" or "ntsc"
+	SampleRate   int    // audio sample rate in hz
+	TargetSystem string // target computer system (e.g., "c64")
+	Waveform     string // waveform used for pulses: "square", "sine" or "triangle"
+	Amplitude    byte   // pulse amplitude (1-127) around the 128 dc offset
+}
+
+// DefaultOptions returns the canonical default settings: pal clock, 44100 hz,
+// c64 target, square waveform and full amplitude (127).
+func DefaultOptions() Options {
+	return Options{
+		ClockType:    constants.DefaultClockType,
+		SampleRate:   int(constants.SampleRate),
+		TargetSystem: constants.DefaultTargetSystem,
+		Waveform:     constants.DefaultWaveform,
+		Amplitude:    constants.DefaultAmplitude,
+	}
+}
+
+// Clock returns the clock frequency for the configured clock standard.
+func (o Options) Clock() (float64, error) {
+	return SelectClock(o.ClockType)
+}
+
+// ProcessOptions returns the audio processing options derived from these settings
+// (target-specific lead detection, the pulse waveform and amplitude).
+func (o Options) ProcessOptions() audio.ProcessOptions {
+	var processOpts audio.ProcessOptions
+	processOpts.LeadPulseValue, processOpts.LeadPulseTolerance = audio.LeadPulseForTarget(o.TargetSystem)
+	processOpts.Waveform = audio.Waveform(strings.ToLower(o.Waveform))
+	processOpts.Amplitude = o.Amplitude
+	return processOpts
+}
+
+// PackageOptions returns the .cpk package options matching these options (the pulse waveform
+// and amplitude recorded in the manifest), for SplitAndPackageBlocks and export.RenderBlockWAV alike.
+func (o Options) PackageOptions() export.PackageOptions {
+	return export.PackageOptions{Waveform: audio.Waveform(strings.ToLower(o.Waveform)), Amplitude: o.Amplitude}
+}
+
+// SelectClock returns the clock frequency for a clock standard ("pal" or "ntsc", case-insensitive).
+func SelectClock(clockType string) (float64, error) {
+	switch strings.ToLower(clockType) {
+	case "ntsc":
+		return constants.ClockNTSC, nil
+	case "pal":
+		return constants.ClockPAL, nil
+	default:
+		return 0, fmt.Errorf("invalid clock type '%s' (must be 'pal' or 'ntsc')", clockType)
+	}
+}
@@ -0,0 +1,40 @@
+// internal/options/options_test.go
+
+package options
+
+import (
+	"go_chirp_the_tap/internal/audio"
+	"go_chirp_the_tap/internal/constants"
+	"testing"
+)
+
+func TestDefaultOptions(t *testing.T) {
+	want := Options{ClockType: "pal", SampleRate: 44100, TargetSystem: "c64", Waveform: "square", Amplitude: 127}
+	opts := DefaultOptions()
+	if opts != want {
+		t.Errorf("DefaultOptions() = %+v, want %+v", opts, want)
+	}
+
+	clock, err := opts.Clock()
+	if err != nil || clock != constants.ClockPAL {
+		t.Errorf("default clock = %v (error %v), want pal %v", clock, err, constants.ClockPAL)
+	}
+	processOpts := opts.ProcessOptions()
+	if processOpts.LeadPulseValue != constants.LeadPulseC64 || processOpts.LeadPulseTolerance != constants.LeadPulseToleranceC64 {
+		t.Errorf("default lead pulse = 0x%02x +/- 0x%02x, want the c64 pilot", processOpts.LeadPulseValue, processOpts.LeadPulseTolerance)
+	}
+	if processOpts.Waveform != audio.WaveSquare || processOpts.Amplitude != 127 {
+		t.Errorf("default waveform %q, amplitude %d, want square at 127", processOpts.Waveform, processOpts.Amplitude)
+	}
+}
+
+func TestSelectClock(t *testing.T) {
+	for name, want := range map[string]float64{"pal": constants.ClockPAL, "NTSC": constants.ClockNTSC} {
+		if clock, err := SelectClock(name); err != nil || clock != want {
+			t.Errorf("SelectClock(%q) = %v, %v; want %v", name, clock, err, want)
+		}
+	}
+	if _, err := SelectClock("secam"); err == nil {
+		t.Errorf("SelectClock accepted an unknown clock type")
+	}
+}
@@ -0,0 +1,61 @@
+// internal/tap/fetch.go
+
+package tap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// IsURL reports whether input is an http:// or https:// url rather than a file path.
+func IsURL(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// FetchTAPImages downloads a .tap file from rawURL into memory and splits and validates it
+// like ReadTAPImages. redirects are followed; the download is aborted after timeout, and if
+// the body exceeds maxSize bytes.
+func FetchTAPImages(rawURL string, maxSize int64, timeout time.Duration) ([][]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading tap file '%s': %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading tap file '%s': server returned %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("error downloading tap file '%s': size %d bytes exceeds limit of %d bytes", rawURL, resp.ContentLength, maxSize)
+	}
+
+	// read one byte beyond the limit to detect oversized bodies without a content length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error downloading tap file '%s': %w", rawURL, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("error downloading tap file '%s': exceeds limit of %d bytes", rawURL, maxSize)
+	}
+
+	return ParseTAPImages(data, rawURL)
+}
+
+// URLBaseName returns the file name of the path of rawURL (e.g. "game.tap"), or "download.tap"
+// if the url has no usable file name.
+func URLBaseName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err == nil {
+		if name := path.Base(parsed.Path); name != "." && name != "/" && name != "" {
+			return name
+		}
+	}
+	return "download.tap"
+}
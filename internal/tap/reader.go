@@ -12,11 +12,84 @@ import (
 	"os"
 )
 
-// ReadTAP opens, validates, and reads the entire content of a .tap file (v0 or v1).
+// ReadTAP opens, validates, and reads the entire content of a .tap file (v0, v1 or v2).
 // it checks the file signature, version, minimum length and declared data size
 // against the actual file size.
 // on success, it returns the full byte content of the file (including the header).
 func ReadTAP(filepath string) ([]byte, error) {
+	data, err := _readFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTAP(data, fmt.Sprintf("'%s'", filepath)); err != nil {
+		// a size mismatch is most likely caused by several tap images concatenated into one file
+		if images := SplitTAPImages(data); len(images) > 1 {
+			return nil, fmt.Errorf("invalid tap file '%s': file contains %d concatenated tap images", filepath, len(images))
+		}
+		return nil, err
+	}
+
+	// if checks pass, return file
+	return data, nil
+}
+
+// ReadTAPImages reads a .tap file that may hold several concatenated .tap images (as produced
+// by some archive tools), each with its own header. every image is validated like ReadTAP does.
+// on success, it returns the full byte content (including the header) of each image in order.
+func ReadTAPImages(filepath string) ([][]byte, error) {
+	data, err := _readFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTAPImages(data, filepath)
+}
+
+// ParseTAPImages splits in-memory data holding one or more concatenated .tap images and
+// validates each like ReadTAPImages does. source names the data in error messages.
+func ParseTAPImages(data []byte, source string) ([][]byte, error) {
+	images := SplitTAPImages(data)
+	for n, image := range images {
+		name := fmt.Sprintf("'%s'", source)
+		if len(images) > 1 {
+			name = fmt.Sprintf("'%s' (image %d of %d)", source, n+1, len(images))
+		}
+		if err := ValidateTAP(image, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return images, nil
+}
+
+// SplitTAPImages splits data holding one or more concatenated .tap images into separate images.
+// images are chained by their declared data size; if the declared size does not lead to another
+// signature (or the end of data), the next signature within the payload is used as boundary.
+// data without any embedded signature is returned as a single image.
+func SplitTAPImages(data []byte) [][]byte {
+	signature := []byte(constants.TapSignatureC64)
+	var images [][]byte
+
+	start := 0
+	for start < len(data) {
+		end := len(data)
+		if start+constants.TapHeaderSize <= len(data) {
+			declaredEnd := start + constants.TapHeaderSize + int(binary.LittleEndian.Uint32(data[start+16:start+20]))
+			if declaredEnd == len(data) || (declaredEnd < len(data) && bytes.HasPrefix(data[declaredEnd:], signature)) {
+				end = declaredEnd
+			} else if next := bytes.Index(data[start+constants.TapHeaderSize:], signature); next >= 0 {
+				end = start + constants.TapHeaderSize + next
+			}
+		}
+		images = append(images, data[start:end])
+		start = end
+	}
+
+	return images
+}
+
+// _readFile reads the entire content of the file at filepath.
+func _readFile(filepath string) ([]byte, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("error opening tap file '%s': %w", filepath, err)
@@ -27,23 +100,28 @@ func ReadTAP(filepath string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error reading tap file '%s': %w", filepath, err)
 	}
+	return data, nil
+}
 
+// ValidateTAP checks signature, version, minimum length and declared data size of a
+// single in-memory .tap image. name identifies the image in error messages.
+func ValidateTAP(data []byte, name string) error {
 	// check minimum length: valid .tap files must be atleast as long as the size of a header...
 	if len(data) < constants.TapHeaderSize {
-		return nil, fmt.Errorf("invalid tap file '%s': file too short (%d bytes found, %d required)", filepath, len(data), constants.TapHeaderSize)
+		return fmt.Errorf("invalid tap file %s: file too short (%d bytes found, %d required)", name, len(data), constants.TapHeaderSize)
 	}
 
 	// check file for valid file signature
 	signature := data[0 : 0+12]
 	expectedSignature := []byte(constants.TapSignatureC64)
 	if !bytes.Equal(signature, expectedSignature) {
-		return nil, fmt.Errorf("invalid tap file '%s': incorrect signature (expected '%s', got '%s')", filepath, constants.TapSignatureC64, string(signature))
+		return fmt.Errorf("invalid tap file %s: incorrect signature (expected '%s', got '%s')", name, constants.TapSignatureC64, string(signature))
 	}
 
 	// check for supported .tap version
 	version := data[12]
 	if version > constants.TapMaxVersionSupport {
-		return nil, fmt.Errorf("invalid tap file '%s': unsupported version %d (only versions <= %d supported)", filepath, version, constants.TapMaxVersionSupport)
+		return fmt.Errorf("invalid tap file %s: unsupported version %d (only versions <= %d supported)", name, version, constants.TapMaxVersionSupport)
 	}
 
 	// check declared data size against actual file data size
@@ -52,9 +130,8 @@ func ReadTAP(filepath string) ([]byte, error) {
 	actualDataSize := uint32(len(data) - constants.TapHeaderSize) // actual number of bytes after header
 
 	if actualDataSize != expectedDataSize {
-		return nil, fmt.Errorf("invalid tap file '%s': declared data size (in header) (%d) does not match actual data size (%d)", filepath, expectedDataSize, actualDataSize)
+		return fmt.Errorf("invalid tap file %s: declared data size (in header) (%d) does not match actual data size (%d)", name, expectedDataSize, actualDataSize)
 	}
 
-	// if checks pass, return file
-	return data, nil
+	return nil
 }
@@ -0,0 +1,66 @@
+// internal/tap/writer.go
+
+package tap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go_chirp_the_tap/internal/constants"
+	"math"
+)
+
+const (
+	maxOverflowCycles = 0xffffff // largest duration of one v1 overflow sequence (24-bit)
+	v0PauseCycles     = 20000    // duration this tool assumes for a v0 overflow, see audio._pauseCycles
+)
+
+// EncodeTAP builds a complete .tap image (header and payload) of the given version from pulse
+// durations in cpu cycles. pulses that fit a pulse byte (8 cycles per unit, 1-255) are written
+// as such; longer ones become overflow sequences: in v1 a 0x00 followed by the exact 24-bit
+// cycle count (split into several sequences beyond 0xffffff cycles), in v0 as many 0x00
+// sequences as approximate the duration at 20000 cycles each (the 3 length bytes are written
+// as well, since ReadTAP-based processing skips them). v2 stores every pulse as two half-waves
+// (high then low) of half its duration each, encoded like v1. the header carries the signature,
+// the version and the exact declared payload size, so the image passes ValidateTAP.
+func EncodeTAP(pulseCycles []uint32, version byte) ([]byte, error) {
+	if version > constants.TapMaxVersionSupport {
+		return nil, fmt.Errorf("unsupported tap version %d (only versions <= %d supported)", version, constants.TapMaxVersionSupport)
+	}
+	if version == constants.TapVersionHalfWave {
+		halfWaves := make([]uint32, 0, 2*len(pulseCycles))
+		for _, cycles := range pulseCycles {
+			halfWaves = append(halfWaves, cycles/2, cycles-cycles/2)
+		}
+		pulseCycles = halfWaves
+	}
+
+	data := make([]byte, constants.TapHeaderSize, constants.TapHeaderSize+len(pulseCycles))
+	copy(data, constants.TapSignatureC64)
+	data[12] = version // bytes 13-15 (platform, video standard, reserved) stay 0 = c64/pal
+
+	for _, cycles := range pulseCycles {
+		value := math.Round(float64(cycles) / constants.TapCyclesPerUnit)
+		switch {
+		case value <= 255:
+			data = append(data, byte(max(1, value))) // 0 would start an overflow, keep the shortest pulse
+		case version == 0:
+			for n := max(1, int(math.Round(float64(cycles)/v0PauseCycles))); n > 0; n-- {
+				data = _appendOverflow(data, v0PauseCycles)
+			}
+		default:
+			for remaining := cycles; remaining > 0; {
+				chunk := min(remaining, maxOverflowCycles)
+				data = _appendOverflow(data, chunk)
+				remaining -= chunk
+			}
+		}
+	}
+
+	binary.LittleEndian.PutUint32(data[16:20], uint32(len(data)-constants.TapHeaderSize))
+	return data, nil
+}
+
+// _appendOverflow appends a 0x00 overflow sequence with a 24-bit little-endian cycle count.
+func _appendOverflow(data []byte, cycles uint32) []byte {
+	return append(data, 0, byte(cycles), byte(cycles>>8), byte(cycles>>16))
+}
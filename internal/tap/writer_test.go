@@ -0,0 +1,124 @@
+// internal/tap/writer_test.go
+
+package tap
+
+import (
+	"bytes"
+	"go_chirp_the_tap/internal/constants"
+	"go_chirp_the_tap/internal/testutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// _writeTAP writes data to a temporary .tap file and returns its path.
+func _writeTAP(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.tap")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// _pulseCycles decodes the payload of a .tap image into pulse durations in cpu cycles, the
+// inverse of EncodeTAP: v0 overflows are 20000 cycles (their 3 length bytes are skipped), v1
+// overflows carry their 24-bit cycle count and v2 half-waves are joined in pairs.
+func _pulseCycles(t *testing.T, data []byte) []uint32 {
+	t.Helper()
+	version := data[12]
+	var cycles []uint32
+	for i := constants.TapHeaderSize; i < len(data); {
+		switch {
+		case data[i] != 0:
+			cycles = append(cycles, uint32(data[i])*constants.TapCyclesPerUnit)
+			i++
+		case i+4 > len(data):
+			t.Fatalf("truncated overflow at offset %d", i)
+		case version == 0:
+			cycles = append(cycles, v0PauseCycles)
+			i += 4
+		default:
+			cycles = append(cycles, uint32(data[i+1])|uint32(data[i+2])<<8|uint32(data[i+3])<<16)
+			i += 4
+		}
+	}
+	if version != constants.TapVersionHalfWave {
+		return cycles
+	}
+	if len(cycles)%2 != 0 {
+		t.Fatalf("odd number of half-waves: %d", len(cycles))
+	}
+	pulses := make([]uint32, 0, len(cycles)/2)
+	for i := 0; i < len(cycles); i += 2 {
+		pulses = append(pulses, cycles[i]+cycles[i+1])
+	}
+	return pulses
+}
+
+// _overflow returns a 0x00 overflow sequence with a 24-bit cycle count.
+func _overflow(cycles uint32) []byte {
+	return []byte{0, byte(cycles), byte(cycles >> 8), byte(cycles >> 16)}
+}
+
+func TestEncodeTAPRoundTrip(t *testing.T) {
+	lead, data := testutil.Lead(100), testutil.Data(50)
+	// v2 stores each pulse as two equal half-waves of half its duration
+	halves := func(pulses []byte) []byte {
+		var out []byte
+		for _, p := range pulses {
+			out = append(out, p/2, p/2)
+		}
+		return out
+	}
+	v0Pause := _overflow(v0PauseCycles) // v0 overflows carry 3 (ignored) length bytes as well
+	tests := []struct {
+		name    string
+		version byte
+		payload []byte
+	}{
+		{"v0", 0, slices.Concat(lead, v0Pause, data, v0Pause, v0Pause, lead)},
+		{"v1", 1, slices.Concat(lead, _overflow(20000), data, _overflow(985248), _overflow(0xffffff), _overflow(0xffffff), data)},
+		{"v2", 2, slices.Concat(halves(lead), _overflow(10000), _overflow(10000), halves(data), _overflow(492624), _overflow(492624))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, err := ReadTAP(_writeTAP(t, testutil.TAP(tt.version, tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := EncodeTAP(_pulseCycles(t, original), tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reread, err := ReadTAP(_writeTAP(t, encoded))
+			if err != nil {
+				t.Fatalf("re-encoded tap is invalid: %v", err)
+			}
+			if !bytes.Equal(reread, original) {
+				t.Errorf("round trip changed the image: %d bytes, want %d\ngot  % x\nwant % x", len(reread), len(original), reread[:min(64, len(reread))], original[:min(64, len(original))])
+			}
+		})
+	}
+}
+
+func TestEncodeTAPHalfWaveReadsBack(t *testing.T) {
+	// pulse durations as a v2 image represents them exactly: even multiples of 8 cycles
+	// (two equal half-waves) and overflows of even cycle counts
+	pulses := []uint32{384, 528, 688, 16, 2032, 40000, 985248, 384}
+	encoded, err := EncodeTAP(pulses, constants.TapVersionHalfWave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ReadTAP(_writeTAP(t, encoded))
+	if err != nil {
+		t.Fatalf("ReadTAP rejects the v2 image: %v", err)
+	}
+	if data[12] != constants.TapVersionHalfWave {
+		t.Errorf("version %d, want %d", data[12], constants.TapVersionHalfWave)
+	}
+	if got := _pulseCycles(t, data); !slices.Equal(got, pulses) {
+		t.Errorf("pulses %v, want %v", got, pulses)
+	}
+}
@@ -0,0 +1,83 @@
+// internal/testutil/tap.go
+
+// Package testutil builds synthetic .tap images for the tests and benchmarks of the other packages.
+package testutil
+
+import (
+	"encoding/binary"
+	"go_chirp_the_tap/internal/constants"
+	"os"
+	"testing"
+)
+
+// dataPattern is the repeating pulse sequence of Data: short, medium and long pulses (0x30,
+// 0x42, 0x56 are the c64 rom loader widths) in an order without runs that look like a lead.
+var dataPattern = []byte{0x30, 0x42, 0x56, 0x30, 0x30, 0x42, 0x56, 0x42, 0x30, 0x56}
+
+// TAP returns a complete .tap image of the given version around payload, with the c64
+// signature and the exact declared payload size, so it passes tap.ValidateTAP.
+func TAP(version byte, payload []byte) []byte {
+	data := make([]byte, constants.TapHeaderSize, constants.TapHeaderSize+len(payload))
+	copy(data, constants.TapSignatureC64)
+	data[12] = version
+	binary.LittleEndian.PutUint32(data[16:20], uint32(len(payload)))
+	return append(data, payload...)
+}
+
+// Lead returns n pilot pulses of the c64 lead pulse width.
+func Lead(n int) []byte {
+	lead := make([]byte, n)
+	for i := range lead {
+		lead[i] = constants.LeadPulseC64
+	}
+	return lead
+}
+
+// Data returns n data pulses cycling through dataPattern.
+func Data(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = dataPattern[i%len(dataPattern)]
+	}
+	return data
+}
+
+// Pause returns a v1 overflow sequence (0x00 and a 24-bit little-endian cycle count).
+func Pause(cycles uint32) []byte {
+	return []byte{0, byte(cycles), byte(cycles >> 8), byte(cycles >> 16)}
+}
+
+// MultiBlockPayload returns the payload of a tape with the given number of files, each one a
+// lead and a short data block (the header), then a lead and a data block of dataBytes pulses,
+// every one of them followed by a pause. the index of such a tape alternates lead, pause, data
+// and pause entries. every lead is just long enough to be detected as one
+// (constants.MinLeadToneLength).
+func MultiBlockPayload(files, dataBytes int) []byte {
+	var payload []byte
+	for range files {
+		payload = append(payload, Lead(constants.MinLeadToneLength)...)
+		payload = append(payload, Pause(20000)...)
+		payload = append(payload, Data(192)...)
+		payload = append(payload, Pause(100000)...)
+		payload = append(payload, Lead(constants.MinLeadToneLength)...)
+		payload = append(payload, Pause(20000)...)
+		payload = append(payload, Data(dataBytes)...)
+		payload = append(payload, Pause(500000)...)
+	}
+	return payload
+}
+
+// Quiet discards everything written to os.Stdout until the test ends, silencing the progress
+// output of the packager and generator in tests and benchmarks.
+func Quiet(tb testing.TB) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatalf("error opening %s: %v", os.DevNull, err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
@@ -9,7 +9,9 @@ import (
 	"go_chirp_the_tap/internal/constants"
 	"go_chirp_the_tap/internal/export"
 	"go_chirp_the_tap/internal/idx"
+	"go_chirp_the_tap/internal/options"
 	"go_chirp_the_tap/internal/tap"
+	"go_chirp_the_tap/version"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +22,12 @@ func TestExport() string {
 	return "hello from go"
 }
 
+// VersionString returns the engine version with commit and build date (if known),
+// e.g. "v1.2.0 (abc1234, 2025-01-31)", for display in the app and bug reports.
+func VersionString() string {
+	return version.Info().String()
+}
+
 // ProcessTAP2Pack creates a .cpk package from a .tap file.
 // this is the main entry point for the mobile frontend. it handles file i/o,
 // processes the raw tape data into audio samples, and packages the output.
@@ -33,20 +41,110 @@ func TestExport() string {
 //   - string: the absolute path to the new .cpk file on success.
 //   - error: an error if any part of the process fails.
 func ProcessTAP2Pack(tapFilePath string, clockType string, targetSystem string) (string, error) {
+	result, err := ProcessTAP2PackWithResult(tapFilePath, clockType, targetSystem)
+	if err != nil {
+		return "", err
+	}
+	return result.Path, nil
+}
+
+// ProcessTAP2PackResult summarises a .cpk package created by ProcessTAP2PackWithResult,
+// so the frontend can show it without unpacking the archive.
+// fields are simple types only, as required by gomobile.
+type ProcessTAP2PackResult struct {
+	Path       string  // absolute path to the new .cpk file
+	BlockCount int     // number of block .wav files in the package
+	Names      string  // newline separated list of distinct idx tags (names) found on the tape; empty if none
+	Duration   float64 // total audio duration in seconds
+}
+
+// ProcessTAP2PackWithResult creates a .cpk package from a .tap file like ProcessTAP2Pack,
+// but returns a summary of the package instead of only its path.
+//
+// parameters:
+//   - tapFilePath: absolute path to the source .tap file.
+//   - clockType: clock standard to use ("pal" or "ntsc").
+//   - targetSystem: target computer system (e.g., "c64").
+//
+// returns:
+//   - *ProcessTAP2PackResult: path and summary of the new .cpk file on success.
+//   - error: an error if any part of the process fails.
+func ProcessTAP2PackWithResult(tapFilePath string, clockType string, targetSystem string) (*ProcessTAP2PackResult, error) {
 	// construct paths based on the input file.
 	baseFilePath := tapFilePath[:len(tapFilePath)-len(filepath.Ext(tapFilePath))]
 	outputPackPath := baseFilePath + ".cpk"
+
+	// start from the shared defaults and apply the caller's settings.
+	opts := options.DefaultOptions()
+	opts.ClockType = clockType
+	opts.TargetSystem = targetSystem
+
+	// read and process the tape data into pcm samples and a block index.
+	pcmSamples, indexData, clock, err := _processTAPFile(tapFilePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the final .cpk package.
+	blockCount, err := export.SplitAndPackageBlocks(pcmSamples, indexData, baseFilePath, opts.SampleRate, clock, opts.TargetSystem, opts.PackageOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cpk package: %w", err)
+	}
+
+	// return the path to the new package with its summary.
+	return &ProcessTAP2PackResult{
+		Path:       outputPackPath,
+		BlockCount: blockCount,
+		Names:      _idxNames(indexData),
+		Duration:   float64(len(pcmSamples)) / float64(opts.SampleRate),
+	}, nil
+}
+
+// RenderBlockWAV returns the audio of a single block of a .tap file as complete .wav bytes.
+// this lets the frontend preview a block on demand without building the whole package.
+//
+// parameters:
+//   - tapFilePath: absolute path to the source .tap file.
+//   - clockType: clock standard to use ("pal" or "ntsc").
+//   - targetSystem: target computer system (e.g., "c64").
+//   - blockNumber: number of the block as listed in blocks.csv (block_NNN_*.wav).
+//
+// returns:
+//   - []byte: the .wav file content of the block.
+//   - error: an error if any part of the process fails.
+func RenderBlockWAV(tapFilePath string, clockType string, targetSystem string, blockNumber int) ([]byte, error) {
+	opts := options.DefaultOptions()
+	opts.ClockType = clockType
+	opts.TargetSystem = targetSystem
+
+	pcmSamples, indexData, _, err := _processTAPFile(tapFilePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// same package options as ProcessTAP2Pack, so the block matches the one in the package
+	wavData, err := export.RenderBlockWAV(pcmSamples, indexData, blockNumber, opts.SampleRate, opts.PackageOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render block %d: %w", blockNumber, err)
+	}
+	return wavData, nil
+}
+
+// _processTAPFile reads a .tap file (and its optional sibling .idx file) and processes it
+// into pcm samples and a block index using opts. it also returns the selected clock frequency.
+func _processTAPFile(tapFilePath string, opts options.Options) ([]byte, []audio.IndexEntry, float64, error) {
+	baseFilePath := tapFilePath[:len(tapFilePath)-len(filepath.Ext(tapFilePath))]
 	idxFilePath := baseFilePath + ".idx"
 
 	// read the raw .tap file.
 	tapData, err := tap.ReadTAP(tapFilePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read tap file %s: %w", tapFilePath, err)
+		return nil, nil, 0, fmt.Errorf("failed to read tap file %s: %w", tapFilePath, err)
 	}
 
 	// validate header and get version.
 	if len(tapData) < constants.TapHeaderSize {
-		return "", fmt.Errorf("invalid tap file %s, smaller than header size", tapFilePath)
+		return nil, nil, 0, fmt.Errorf("invalid tap file %s, smaller than header size", tapFilePath)
 	}
 	tapVersion := tapData[12]
 
@@ -55,7 +153,7 @@ func ProcessTAP2Pack(tapFilePath string, clockType string, targetSystem string)
 	if _, err := os.Stat(idxFilePath); err == nil {
 		idxEntries, err = idx.ReadIDX(idxFilePath)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse idx file %s: %w", idxFilePath, err)
+			return nil, nil, 0, fmt.Errorf("failed to parse idx file %s: %w", idxFilePath, err)
 		}
 	} else if !os.IsNotExist(err) {
 		// log a warning if we can't check for the file, but don't fail.
@@ -63,31 +161,35 @@ func ProcessTAP2Pack(tapFilePath string, clockType string, targetSystem string)
 	}
 
 	// select the correct clock frequency.
-	var clock float64
-	switch strings.ToLower(clockType) {
-	case "ntsc":
-		clock = constants.ClockNTSC
-	case "pal":
-		clock = constants.ClockPAL
-	default:
-		return "", fmt.Errorf("invalid clock type '%s'", clockType)
+	clock, err := opts.Clock()
+	if err != nil {
+		return nil, nil, 0, err
 	}
 
 	// process the tape data into pcm samples and a block index.
-	pcmSamples, indexData, err := audio.ProcessTAPData(tapData, tapVersion, clock, constants.SampleRate, idxEntries)
+	pcmSamples, indexData, err := audio.ProcessTAPData(tapData, tapVersion, clock, float64(opts.SampleRate), idxEntries, opts.ProcessOptions())
 	if err != nil {
-		return "", fmt.Errorf("failed to process tap data: %w", err)
+		return nil, nil, 0, fmt.Errorf("failed to process tap data: %w", err)
 	}
 	if len(pcmSamples) == 0 {
-		return "", errors.New("processing resulted in no audio samples")
+		return nil, nil, 0, errors.New("processing resulted in no audio samples")
 	}
 
-	// create the final .cpk package.
-	err = export.SplitAndPackageBlocks(pcmSamples, indexData, baseFilePath, int(constants.SampleRate), clock, targetSystem)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cpk package: %w", err)
-	}
+	return pcmSamples, indexData, clock, nil
+}
 
-	// return the path to the new package.
-	return outputPackPath, nil
+// _idxNames returns the distinct non-empty idx tags of indexData in tape order,
+// separated by newlines (gomobile can't pass string slices).
+func _idxNames(indexData []audio.IndexEntry) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, entry := range indexData {
+		name := strings.TrimSpace(strings.ReplaceAll(entry.IDXTag, "\n", " "))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return strings.Join(names, "\n")
 }
@@ -9,6 +9,12 @@ output_name="go_chirp_the_tap.aar"
 output_path="$output_dir/$output_name"
 package_path="./mobile" # go package containing exported api
 
+# version info embedded via ldflags (see version/version.go)
+version_pkg="go_chirp_the_tap/version"
+build_version=$(git describe --tags --always --dirty 2>/dev/null || echo "dev")
+build_commit=$(git rev-parse HEAD 2>/dev/null)
+build_date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+
 # android ndk check (essential for gomobile)
 if [ -z "$ANDROID_NDK_HOME" ]; then
     echo "error: ANDROID_NDK_HOME environment variable is not set."
@@ -29,6 +35,7 @@ fi
 gomobile bind -v\
     -target=android/arm,android/arm64 \
     -androidapi 21 \
+    -ldflags="-X $version_pkg.version=$build_version -X $version_pkg.commit=$build_commit -X $version_pkg.buildDate=$build_date" \
     -o "$output_path" \
     "$package_path"
 
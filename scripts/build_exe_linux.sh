@@ -7,7 +7,13 @@
 output_dir="./build/linux_amd64"
 output_name="go_chirp_the_tap"
 output_path="$output_dir/$output_name"
-source_path="./cmd/main.go" # main package source
+source_path="./cmd" # main package source
+
+# version info embedded via ldflags (see version/version.go)
+version_pkg="go_chirp_the_tap/version"
+build_version=$(git describe --tags --always --dirty 2>/dev/null || echo "dev")
+build_commit=$(git rev-parse HEAD 2>/dev/null)
+build_date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
 
 # start build
 echo "Building go_chirp_the_tap for Linux ($output_name)..."
@@ -20,7 +26,7 @@ if [ $? -ne 0 ]; then
 fi
 
 # run go build
-go build -v -ldflags="-s -w" -o "$output_path" "$source_path"
+go build -v -ldflags="-s -w -X $version_pkg.version=$build_version -X $version_pkg.commit=$build_commit -X $version_pkg.buildDate=$build_date" -o "$output_path" "$source_path"
 
 # check build result
 if [ $? -eq 0 ]; then
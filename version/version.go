@@ -0,0 +1,70 @@
+// version/version.go
+
+// package version holds the build version of the engine, shared by the command-line tool,
+// the mobile api and go programs importing the engine (hence not internal). the values are set at build time via ldflags, e.g.:
+//
+//	go build -ldflags="-X go_chirp_the_tap/version.version=v1.2.0 \
+//	  -X go_chirp_the_tap/version.commit=abc1234 \
+//	  -X go_chirp_the_tap/version.buildDate=2025-01-31" ./cmd
+//
+// without ldflags, the commit and date are taken from the vcs info embedded by go build (if any).
+package version
+
+import "runtime/debug"
+
+// set via -ldflags "-X ..."; must stay plain string variables for that to work
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo describes the build of the engine.
+type BuildInfo struct {
+	Version   string `json:"version"`    // release version (e.g. v1.2.0), "dev" for untagged builds
+	Commit    string `json:"commit"`     // vcs revision the binary was built from ("" if unknown)
+	BuildDate string `json:"build_date"` // build (or commit) date ("" if unknown)
+}
+
+// Version returns the release version of the engine.
+func Version() string {
+	return version
+}
+
+// Info returns the build information, filling commit and date from the embedded vcs info
+// if they were not set via ldflags.
+func Info() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// String formats the build information as a single line, e.g. "v1.2.0 (abc1234, 2025-01-31)".
+func (b BuildInfo) String() string {
+	details := ""
+	if b.Commit != "" {
+		details = b.Commit
+		if len(details) > 12 {
+			details = details[:12]
+		}
+	}
+	if b.BuildDate != "" {
+		if details != "" {
+			details += ", "
+		}
+		details += b.BuildDate
+	}
+	if details == "" {
+		return b.Version
+	}
+	return b.Version + " (" + details + ")"
+}
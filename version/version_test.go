@@ -0,0 +1,22 @@
+// version/version_test.go
+
+package version
+
+import "testing"
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		info BuildInfo
+		want string
+	}{
+		{BuildInfo{Version: "dev"}, "dev"},
+		{BuildInfo{Version: "v1.2.0", Commit: "abc1234"}, "v1.2.0 (abc1234)"},
+		{BuildInfo{Version: "v1.2.0", BuildDate: "2025-01-31"}, "v1.2.0 (2025-01-31)"},
+		{BuildInfo{Version: "v1.2.0", Commit: "0123456789abcdef", BuildDate: "2025-01-31"}, "v1.2.0 (0123456789ab, 2025-01-31)"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}